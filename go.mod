@@ -1,5 +1,5 @@
 module tideland.dev/go/trace
 
-go 1.14
+go 1.21
 
 require tideland.dev/go/audit v0.4.0
@@ -66,15 +66,29 @@ func At(offset int) Location {
 	if n == 0 {
 		return Location{}
 	}
-	pcs = pcs[:n]
-	// Check cache.
-	pc := pcs[0]
+	return lookup(pcs[0])
+}
+
+// ForPC returns the location of the given program counter like
+// it is provided by runtime.Callers().
+func ForPC(pc uintptr) Location {
+	if pc == 0 {
+		return Location{}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return lookup(pc)
+}
+
+// lookup returns the cached location of the program counter or
+// builds and caches a new one.
+func lookup(pc uintptr) Location {
 	l, ok := locations[pc]
 	if ok {
 		return l
 	}
-	// Build ID based on program counters.
-	frames := runtime.CallersFrames(pcs)
+	// Build ID based on program counter.
+	frames := runtime.CallersFrames([]uintptr{pc})
 	for {
 		frame, more := frames.Next()
 		pkg, fun := path.Split(frame.Function)
@@ -0,0 +1,45 @@
+// Tideland Go Trace - Location - Unit Tests
+//
+// Copyright (C) 2017-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package location_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"runtime"
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/trace/location"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestForPC tests retrieving the location of a program counter.
+func TestForPC(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+
+	pcs := make([]uintptr, 1)
+	n := runtime.Callers(1, pcs)
+	assert.Equal(n, 1)
+	_, _, line, ok := runtime.Caller(0)
+	assert.True(ok)
+
+	l := location.ForPC(pcs[0])
+
+	assert.Equal(l.Package, "tideland.dev/go/trace/location_test")
+	assert.Equal(l.File, "pc_test.go")
+	assert.Equal(l.Func, "TestForPC")
+	assert.Equal(l.Line, line-2)
+	assert.Equal(location.ForPC(0), location.Location{})
+}
+
+// EOF
@@ -0,0 +1,235 @@
+// Tideland Go Trace - Logger - Asynchronous Writer
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger // import "tideland.dev/go/trace/logger"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"sync"
+	"time"
+
+	"tideland.dev/go/trace/failure"
+)
+
+//--------------------
+// ASYNC WRITER
+//--------------------
+
+// defaultQueueSize is used if no queue size is configured.
+const defaultQueueSize = 1024
+
+// AsyncPolicy defines the behavior of an asynchronous writer
+// in case of a full queue.
+type AsyncPolicy int
+
+// Policies for full queues of asynchronous writers.
+const (
+	// AsyncBlock lets the logging goroutine wait until the
+	// queue has space again.
+	AsyncBlock AsyncPolicy = iota
+
+	// AsyncDropNewest drops the entry to write.
+	AsyncDropNewest
+
+	// AsyncDropOldest drops the oldest queued entry.
+	AsyncDropOldest
+
+	// AsyncDropBelow drops the entry to write if its level is below
+	// the configured one, otherwise it blocks.
+	AsyncDropBelow
+)
+
+// AsyncConfig contains the configuration of an asynchronous writer.
+type AsyncConfig struct {
+	// QueueSize is the maximum number of queued entries.
+	QueueSize int
+
+	// Policy defines what happens when the queue is full.
+	Policy AsyncPolicy
+
+	// DropBelow is the level below entries are dropped when using
+	// the policy AsyncDropBelow.
+	DropBelow LogLevel
+}
+
+// AsyncStats contains the counters of an asynchronous writer.
+type AsyncStats struct {
+	Queued  int
+	Written uint64
+	Dropped uint64
+	Failed  uint64
+}
+
+// AsyncWriter is a writer queueing the entries and writing them
+// in the background.
+type AsyncWriter interface {
+	EntryWriter
+	Flusher
+
+	// Stats returns the current counters.
+	Stats() AsyncStats
+
+	// Close flushes the queue, stops the writer, and closes the
+	// wrapped writer if it implements io.Closer.
+	Close() error
+}
+
+// asyncWriter implements AsyncWriter.
+type asyncWriter struct {
+	mu      sync.Mutex
+	changed *sync.Cond
+	out     EntryWriter
+	cfg     AsyncConfig
+	queue   []Entry
+	busy    bool
+	closed  bool
+	stats   AsyncStats
+	err     error
+	donec   chan struct{}
+}
+
+// NewAsyncWriter wraps the passed writer with a bounded queue written
+// by a background goroutine. So slow writers don't stall the logging
+// goroutines.
+func NewAsyncWriter(out Writer, cfg AsyncConfig) AsyncWriter {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultQueueSize
+	}
+	w := &asyncWriter{
+		out:   AdaptWriter(out),
+		cfg:   cfg,
+		queue: make([]Entry, 0, cfg.QueueSize),
+		donec: make(chan struct{}),
+	}
+	w.changed = sync.NewCond(&w.mu)
+	go w.backend()
+	return w
+}
+
+// Write implements Writer.
+func (w *asyncWriter) Write(level LogLevel, msg string) error {
+	return w.WriteEntry(Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: msg,
+	})
+}
+
+// WriteEntry implements EntryWriter.
+func (w *asyncWriter) WriteEntry(entry Entry) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for !w.closed && len(w.queue) >= w.cfg.QueueSize {
+		switch w.cfg.Policy {
+		case AsyncDropNewest:
+			w.stats.Dropped++
+			return nil
+		case AsyncDropOldest:
+			w.queue = w.queue[1:]
+			w.stats.Dropped++
+		case AsyncDropBelow:
+			if entry.Level < w.cfg.DropBelow {
+				w.stats.Dropped++
+				return nil
+			}
+			w.changed.Wait()
+		default:
+			w.changed.Wait()
+		}
+	}
+	if w.closed {
+		return failure.New("asynchronous writer is closed")
+	}
+	w.queue = append(w.queue, entry)
+	w.changed.Broadcast()
+	return nil
+}
+
+// unwrap implements wrapper.
+func (w *asyncWriter) unwrap() Writer {
+	return unadaptWriter(w.out)
+}
+
+// Flush implements Flusher. It waits until all queued entries are
+// written, flushes the wrapped writer, and returns the last error
+// of it.
+func (w *asyncWriter) Flush() error {
+	w.mu.Lock()
+	for len(w.queue) > 0 || w.busy {
+		w.changed.Wait()
+	}
+	err := w.err
+	w.err = nil
+	w.mu.Unlock()
+	return failure.Collect(err, flushWriter(w.out))
+}
+
+// Stats implements AsyncWriter.
+func (w *asyncWriter) Stats() AsyncStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	stats := w.stats
+	stats.Queued = len(w.queue)
+	return stats
+}
+
+// Close implements AsyncWriter.
+func (w *asyncWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.changed.Broadcast()
+	w.mu.Unlock()
+	<-w.donec
+	_, cerr := closeWriter(w.out)
+	return failure.Collect(w.err, cerr)
+}
+
+// backend writes the queued entries in the background.
+func (w *asyncWriter) backend() {
+	defer close(w.donec)
+	for {
+		w.mu.Lock()
+		for len(w.queue) == 0 && !w.closed {
+			w.changed.Wait()
+		}
+		if len(w.queue) == 0 {
+			w.mu.Unlock()
+			return
+		}
+		batch := w.queue
+		w.queue = make([]Entry, 0, w.cfg.QueueSize)
+		w.busy = true
+		w.changed.Broadcast()
+		w.mu.Unlock()
+		var written, failed uint64
+		var lastErr error
+		for _, entry := range batch {
+			if err := w.out.WriteEntry(entry); err != nil {
+				failed++
+				lastErr = err
+				continue
+			}
+			written++
+		}
+		w.mu.Lock()
+		w.busy = false
+		w.stats.Written += written
+		w.stats.Failed += failed
+		w.err = failure.First(lastErr, w.err)
+		w.changed.Broadcast()
+		w.mu.Unlock()
+	}
+}
+
+// EOF
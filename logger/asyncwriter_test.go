@@ -0,0 +1,150 @@
+// Tideland Go Trace - Logger - Unit Tests
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"sync"
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/trace/logger"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestAsyncWriter tests the asynchronous writing and the flushing.
+func TestAsyncWriter(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	tw := logger.NewTestWriter()
+	aw := logger.NewAsyncWriter(tw, logger.AsyncConfig{QueueSize: 10})
+	l := logger.New(aw)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				l.Info("entry", "i", i, "j", j)
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Nil(aw.Flush())
+	assert.Length(tw, 1000)
+
+	stats := aw.Stats()
+	assert.Equal(stats.Written, uint64(1000))
+	assert.Equal(stats.Dropped, uint64(0))
+	assert.Nil(aw.Close())
+	assert.ErrorContains(aw.WriteEntry(logger.Entry{}), "closed")
+}
+
+// TestAsyncWriterPolicies tests the policies for full queues.
+func TestAsyncWriterPolicies(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+
+	tests := []struct {
+		policy   logger.AsyncPolicy
+		written  int
+		messages []string
+	}{
+		{logger.AsyncDropNewest, 2, []string{"0", "1"}},
+		{logger.AsyncDropOldest, 2, []string{"4", "5"}},
+		{logger.AsyncDropBelow, 3, []string{"0", "1", "5"}},
+	}
+	for _, test := range tests {
+		bw := newBlockingWriter()
+		aw := logger.NewAsyncWriter(bw, logger.AsyncConfig{
+			QueueSize: 2,
+			Policy:    test.policy,
+			DropBelow: logger.LevelError,
+		})
+		// First entry is taken by the background and blocks.
+		aw.Write(logger.LevelInfo, "blocking")
+		<-bw.started
+		for i := 0; i < 5; i++ {
+			aw.Write(logger.LevelInfo, string(rune('0'+i)))
+		}
+		if test.policy == logger.AsyncDropBelow {
+			// Error entry waits for space in the queue.
+			donec := make(chan struct{})
+			go func() {
+				defer close(donec)
+				aw.Write(logger.LevelError, "5")
+			}()
+			close(bw.release)
+			<-donec
+		} else {
+			aw.Write(logger.LevelInfo, "5")
+			close(bw.release)
+		}
+		assert.Nil(aw.Close())
+		assert.Equal(bw.msgs[1:], test.messages)
+		assert.Equal(aw.Stats().Written, uint64(test.written+1))
+		assert.Equal(aw.Stats().Dropped, uint64(6-test.written))
+	}
+}
+
+// TestAsyncWriterFatal tests the flushing before the fatal exiter.
+func TestAsyncWriterFatal(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	tw := logger.NewTestWriter()
+	aw := logger.NewAsyncWriter(tw, logger.AsyncConfig{})
+	l := logger.New(aw)
+	entries := -1
+	l.SetFatalExiter(func() {
+		entries = tw.Len()
+	})
+
+	l.Info("one")
+	l.Info("two")
+	l.Fatal("three")
+	assert.Equal(entries, 3)
+	assert.Nil(aw.Close())
+}
+
+//--------------------
+// HELPERS
+//--------------------
+
+// blockingWriter blocks at the first write until released.
+type blockingWriter struct {
+	mu      sync.Mutex
+	msgs    []string
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingWriter() *blockingWriter {
+	return &blockingWriter{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+}
+
+// Write implements logger.Writer.
+func (w *blockingWriter) Write(level logger.LogLevel, msg string) error {
+	w.mu.Lock()
+	first := len(w.msgs) == 0
+	w.msgs = append(w.msgs, msg)
+	w.mu.Unlock()
+	if first {
+		close(w.started)
+		<-w.release
+	}
+	return nil
+}
+
+// EOF
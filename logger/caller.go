@@ -0,0 +1,83 @@
+// Tideland Go Trace - Logger - Caller Locations
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger // import "tideland.dev/go/trace/logger"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"runtime"
+)
+
+//--------------------
+// CALLER LOCATIONS
+//--------------------
+
+// DefaultLocationLevels selects the levels logging the location of
+// the caller by default.
+const DefaultLocationLevels LevelMask = 1<<LevelDebug | 1<<LevelCritical | 1<<LevelFatal
+
+// SetLocationLevels sets the mask of levels whose entries get the
+// location of the caller as Location and returns the current one.
+// Retrieving the location costs some time, so AllLevels should be
+// chosen with care in hot paths.
+func (l *Logger) SetLocationLevels(mask LevelMask) LevelMask {
+	l.backend.mu.Lock()
+	defer l.backend.mu.Unlock()
+	current := l.backend.locationLevels
+	l.backend.locationLevels = mask
+	return current
+}
+
+// LocationLevels returns the mask of levels logging the location.
+func (l *Logger) LocationLevels() LevelMask {
+	l.backend.mu.RLock()
+	defer l.backend.mu.RUnlock()
+	return l.backend.locationLevels
+}
+
+// WithCallerSkip returns a child logger skipping the passed number of
+// additional stack frames when retrieving the location. Helper or
+// wrapper functions logging on behalf of their callers use it to
+// report the location of those callers. Negative values are ignored.
+func (l *Logger) WithCallerSkip(skip int) *Logger {
+	if skip < 0 {
+		skip = 0
+	}
+	return &Logger{
+		backend: l.backend,
+		name:    l.name,
+		fields:  l.fields,
+		skip:    l.skip + skip,
+	}
+}
+
+// SetLocationLevels sets the mask of levels logging the location
+// of the default logger.
+func SetLocationLevels(mask LevelMask) LevelMask {
+	return std.SetLocationLevels(mask)
+}
+
+// LocationLevels returns the mask of levels logging the location
+// of the default logger.
+func LocationLevels() LevelMask {
+	return std.LocationLevels()
+}
+
+// callerPC returns the program counter of the caller at the given
+// offset like location.At() without looking up the location.
+func callerPC(offset int) uintptr {
+	pcs := make([]uintptr, 1)
+	if runtime.Callers(offset+2, pcs) == 0 {
+		return 0
+	}
+	return pcs[0]
+}
+
+// EOF
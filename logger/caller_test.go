@@ -0,0 +1,89 @@
+// Tideland Go Trace - Logger - Unit Tests
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/trace/logger"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestLocationLevels tests choosing the levels logging the location.
+func TestLocationLevels(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	l := logger.New(nil)
+	l.SetLevel(logger.LevelDebug)
+	cw := logger.Capture(t, l)
+	assert.Equal(l.LocationLevels(), logger.DefaultLocationLevels)
+
+	l.Debugf("%d%% done", 50)
+	l.Infof("%d%% done", 100)
+	es := cw.Entries()
+	assert.Length(es, 2)
+	assert.Equal(es[0].Message, "50% done")
+	assert.Equal(es[0].Location.Func, "TestLocationLevels")
+	assert.Equal(es[1].Message, "100% done")
+	assert.Equal(es[1].Location.ID, "")
+
+	current := l.SetLocationLevels(logger.MaskOf(logger.LevelInfo, logger.LevelError))
+	assert.Equal(current, logger.DefaultLocationLevels)
+	cw.Reset()
+	l.Debug("debug")
+	l.Info("info")
+	l.Warningf("warning")
+	l.Errorf("error")
+	es = cw.Entries()
+	assert.Length(es, 4)
+	assert.Equal(es[0].Location.ID, "")
+	assert.Equal(es[1].Location.Func, "TestLocationLevels")
+	assert.Equal(es[2].Location.ID, "")
+	assert.Equal(es[3].Location.Func, "TestLocationLevels")
+	assert.Equal(es[3].Message, "error")
+}
+
+// TestCallerSkip tests skipping the frames of helper functions.
+func TestCallerSkip(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	l := logger.New(nil)
+	l.SetLocationLevels(logger.AllLevels)
+	cw := logger.Capture(t, l)
+
+	logHelper(l, "direct")
+	logHelper(l.WithCallerSkip(1), "skipped")
+	logHelper(l.WithCallerSkip(1).Named("child").With("n", 1), "child")
+	logHelper(l.WithCallerSkip(-1), "negative")
+
+	es := cw.Entries()
+	assert.Length(es, 4)
+	assert.Equal(es[0].Location.Func, "logHelper")
+	assert.Equal(es[1].Location.Func, "TestCallerSkip")
+	assert.Equal(es[1].Location.File, "caller_test.go")
+	assert.Equal(es[2].Location.Func, "TestCallerSkip")
+	assert.Equal(es[2].Text(), es[2].Location.ID+" child logger=child n=1")
+	assert.Equal(es[3].Location.Func, "logHelper")
+}
+
+//--------------------
+// HELPERS
+//--------------------
+
+// logHelper logs on behalf of its caller.
+func logHelper(l *logger.Logger, msg string) {
+	l.Infof("%s", msg)
+}
+
+// EOF
@@ -0,0 +1,257 @@
+// Tideland Go Trace - Logger - Capture Writer
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger // import "tideland.dev/go/trace/logger"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+//--------------------
+// CAPTURE WRITER
+//--------------------
+
+// TestingT is the part of testing.TB used by the capture writer.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+	Cleanup(f func())
+}
+
+// CaptureWriter collects the structured entries for the evaluation
+// inside of tests. Patterns are regular expressions matched against
+// the text of the entries containing location, message, and fields.
+// Invalid patterns fail the test the writer has been created for by
+// Capture(), or the passed one for assertions. Without a test they
+// lead to a panic.
+type CaptureWriter interface {
+	EntryWriter
+
+	// Entries returns the captured entries.
+	Entries() []Entry
+
+	// Len returns the number of captured entries.
+	Len() int
+
+	// CountAt returns the number of captured entries with the given level.
+	CountAt(level LogLevel) int
+
+	// Matching returns the captured entries with the given level
+	// matching the pattern.
+	Matching(level LogLevel, pattern string) []Entry
+
+	// WaitFor waits until an entry with the given level matching the
+	// pattern has been captured or the timeout is reached.
+	WaitFor(level LogLevel, pattern string, timeout time.Duration) (Entry, bool)
+
+	// AssertLogged reports an error if no entry with the given level
+	// matches the pattern.
+	AssertLogged(t TestingT, level LogLevel, pattern string) bool
+
+	// AssertNotLogged reports an error if an entry with the given
+	// level matches the pattern.
+	AssertNotLogged(t TestingT, level LogLevel, pattern string) bool
+
+	// Reset clears the captured entries.
+	Reset()
+}
+
+// captureWriter implements CaptureWriter.
+type captureWriter struct {
+	t       TestingT
+	mu      sync.Mutex
+	entries []Entry
+	written chan struct{}
+}
+
+// NewCaptureWriter returns a writer capturing the structured entries
+// for testing purposes.
+func NewCaptureWriter() CaptureWriter {
+	return &captureWriter{
+		written: make(chan struct{}),
+	}
+}
+
+// Capture sets a new capture writer for the logger, or the default
+// logger if nil, and returns it. When the test is done the previous
+// writer is restored.
+func Capture(t TestingT, l *Logger) CaptureWriter {
+	t.Helper()
+	if l == nil {
+		l = std
+	}
+	cw := &captureWriter{
+		t:       t,
+		written: make(chan struct{}),
+	}
+	previous := l.SetWriter(cw)
+	t.Cleanup(func() {
+		l.SetWriter(previous)
+	})
+	return cw
+}
+
+// Write implements Writer.
+func (w *captureWriter) Write(level LogLevel, msg string) error {
+	return w.WriteEntry(Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: msg,
+	})
+}
+
+// WriteEntry implements EntryWriter.
+func (w *captureWriter) WriteEntry(entry Entry) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.entries = append(w.entries, entry)
+	// Wake up all waiting callers.
+	close(w.written)
+	w.written = make(chan struct{})
+	return nil
+}
+
+// Entries implements CaptureWriter.
+func (w *captureWriter) Entries() []Entry {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	entries := make([]Entry, len(w.entries))
+	copy(entries, w.entries)
+	return entries
+}
+
+// Len implements CaptureWriter.
+func (w *captureWriter) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.entries)
+}
+
+// CountAt implements CaptureWriter.
+func (w *captureWriter) CountAt(level LogLevel) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	count := 0
+	for _, entry := range w.entries {
+		if entry.Level == level {
+			count++
+		}
+	}
+	return count
+}
+
+// Matching implements CaptureWriter.
+func (w *captureWriter) Matching(level LogLevel, pattern string) []Entry {
+	re, ok := w.compile(w.t, pattern)
+	if !ok {
+		return nil
+	}
+	entries, _, _ := w.matching(level, re, 0)
+	return entries
+}
+
+// WaitFor implements CaptureWriter.
+func (w *captureWriter) WaitFor(level LogLevel, pattern string, timeout time.Duration) (Entry, bool) {
+	re, ok := w.compile(w.t, pattern)
+	if !ok {
+		return Entry{}, false
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	from := 0
+	for {
+		entries, next, written := w.matching(level, re, from)
+		if len(entries) > 0 {
+			return entries[0], true
+		}
+		from = next
+		select {
+		case <-written:
+		case <-timer.C:
+			return Entry{}, false
+		}
+	}
+}
+
+// AssertLogged implements CaptureWriter.
+func (w *captureWriter) AssertLogged(t TestingT, level LogLevel, pattern string) bool {
+	t.Helper()
+	re, ok := w.compile(t, pattern)
+	if !ok {
+		return false
+	}
+	if entries, _, _ := w.matching(level, re, 0); len(entries) == 0 {
+		t.Errorf("no %s entry matching %q logged", levelToText(level), pattern)
+		return false
+	}
+	return true
+}
+
+// AssertNotLogged implements CaptureWriter.
+func (w *captureWriter) AssertNotLogged(t TestingT, level LogLevel, pattern string) bool {
+	t.Helper()
+	re, ok := w.compile(t, pattern)
+	if !ok {
+		return false
+	}
+	if entries, _, _ := w.matching(level, re, 0); len(entries) > 0 {
+		t.Errorf("%s entry matching %q logged: %s", levelToText(level), pattern, entries[0].Text())
+		return false
+	}
+	return true
+}
+
+// Reset implements CaptureWriter.
+func (w *captureWriter) Reset() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.entries = nil
+}
+
+// compile compiles the pattern. An invalid one fails the test or
+// leads to a panic if there is none.
+func (w *captureWriter) compile(t TestingT, pattern string) (*regexp.Regexp, bool) {
+	re, err := regexp.Compile(pattern)
+	if err == nil {
+		return re, true
+	}
+	if t == nil {
+		panic(fmt.Sprintf("invalid pattern %q: %v", pattern, err))
+	}
+	t.Helper()
+	t.Fatalf("invalid pattern %q: %v", pattern, err)
+	return nil, false
+}
+
+// matching returns the entries starting at the given index with the
+// level matching the pattern. Additionally the index to continue with
+// and the channel signalling the next write are returned.
+func (w *captureWriter) matching(level LogLevel, re *regexp.Regexp, from int) ([]Entry, int, chan struct{}) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var entries []Entry
+	if from > len(w.entries) {
+		// Entries have been reset.
+		from = 0
+	}
+	for _, entry := range w.entries[from:] {
+		if entry.Level == level && re.MatchString(entry.Text()) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, len(w.entries), w.written
+}
+
+// EOF
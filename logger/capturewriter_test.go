@@ -0,0 +1,162 @@
+// Tideland Go Trace - Logger - Unit Tests
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/trace/logger"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestCaptureWriter tests capturing and matching entries.
+func TestCaptureWriter(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	cw := logger.NewCaptureWriter()
+	l := logger.New(cw)
+
+	l.Info("user created", "user", "foo")
+	l.Errorf("connection to %s refused", "db")
+	l.Error("query failed", "code", 42)
+	l.Critical("broken")
+
+	assert.Equal(cw.Len(), 4)
+	assert.Equal(cw.CountAt(logger.LevelError), 2)
+	assert.Equal(cw.CountAt(logger.LevelDebug), 0)
+	assert.True(cw.AssertLogged(t, logger.LevelError, "connection .* refused"))
+	assert.True(cw.AssertNotLogged(t, logger.LevelInfo, "refused"))
+
+	es := cw.Matching(logger.LevelError, "code=42")
+	assert.Length(es, 1)
+	assert.Equal(es[0].Message, "query failed")
+	code, ok := es[0].Field("code")
+	assert.True(ok)
+	assert.Equal(code, 42)
+	es = cw.Matching(logger.LevelCritical, "broken")
+	assert.Length(es, 1)
+	assert.Equal(es[0].Location.Func, "TestCaptureWriter")
+
+	cw.Reset()
+	assert.Equal(cw.Len(), 0)
+}
+
+// TestCaptureWriterAssertions tests the reporting of failed assertions.
+func TestCaptureWriterAssertions(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	cw := logger.NewCaptureWriter()
+	l := logger.New(cw)
+	rt := &recordingT{}
+
+	l.Warning("disk full")
+	assert.False(cw.AssertLogged(rt, logger.LevelError, "disk"))
+	assert.False(cw.AssertNotLogged(rt, logger.LevelWarning, "disk"))
+	assert.False(cw.AssertLogged(rt, logger.LevelWarning, "("))
+	assert.Length(rt.errors, 2)
+	assert.Equal(rt.errors[0], `no ERROR entry matching "disk" logged`)
+	assert.Equal(rt.errors[1], `WARNING entry matching "disk" logged: disk full`)
+	assert.Length(rt.fatals, 1)
+	assert.Contains(`invalid pattern "("`, rt.fatals[0])
+}
+
+// TestCaptureInvalidPattern tests failing the test for invalid patterns.
+func TestCaptureInvalidPattern(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	l := logger.New(nil)
+	rt := &recordingT{}
+	cw := logger.Capture(rt, l)
+
+	l.Warning("disk full")
+	assert.Nil(cw.Matching(logger.LevelWarning, "disk ("))
+	_, ok := cw.WaitFor(logger.LevelWarning, "[disk", time.Second)
+	assert.False(ok)
+	assert.Length(rt.fatals, 2)
+	assert.Contains(`invalid pattern "disk ("`, rt.fatals[0])
+	assert.Contains(`invalid pattern "[disk"`, rt.fatals[1])
+
+	defer func() {
+		assert.Contains(`invalid pattern "("`, fmt.Sprint(recover()))
+	}()
+	logger.NewCaptureWriter().Matching(logger.LevelWarning, "(")
+}
+
+// TestCaptureWriterWait tests waiting for entries.
+func TestCaptureWriterWait(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	cw := logger.NewCaptureWriter()
+	l := logger.New(cw)
+
+	go func() {
+		for i := 0; i < 5; i++ {
+			time.Sleep(10 * time.Millisecond)
+			l.Infof("step %d", i)
+		}
+		l.Error("done")
+	}()
+	entry, ok := cw.WaitFor(logger.LevelError, "done", time.Second)
+	assert.True(ok)
+	assert.Equal(entry.Message, "done")
+	assert.Equal(cw.CountAt(logger.LevelInfo), 5)
+
+	_, ok = cw.WaitFor(logger.LevelError, "never", 50*time.Millisecond)
+	assert.False(ok)
+}
+
+// TestCapture tests the scoping of the capturing to one test.
+func TestCapture(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	tw := logger.NewTestWriter()
+	l := logger.New(tw)
+
+	t.Run("scoped", func(t *testing.T) {
+		cw := logger.Capture(t, l)
+		l.Info("captured")
+		cw.AssertLogged(t, logger.LevelInfo, "^captured$")
+	})
+	l.Info("not captured")
+	assert.Equal(tw.Len(), 1)
+	assert.Contains("not captured", tw.Entries()[0])
+}
+
+//--------------------
+// HELPERS
+//--------------------
+
+// recordingT records the reported errors and fatal failures.
+type recordingT struct {
+	errors []string
+	fatals []string
+}
+
+// Helper implements logger.TestingT.
+func (rt *recordingT) Helper() {}
+
+// Errorf implements logger.TestingT and records the error.
+func (rt *recordingT) Errorf(format string, args ...interface{}) {
+	rt.errors = append(rt.errors, fmt.Sprintf(format, args...))
+}
+
+// Fatalf implements logger.TestingT and records the failure. Other
+// than testing.T it does not stop the test.
+func (rt *recordingT) Fatalf(format string, args ...interface{}) {
+	rt.fatals = append(rt.fatals, fmt.Sprintf(format, args...))
+}
+
+// Cleanup implements logger.TestingT and ignores the function.
+func (rt *recordingT) Cleanup(f func()) {}
+
+// EOF
@@ -0,0 +1,437 @@
+// Tideland Go Trace - Logger - Configuration
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger // import "tideland.dev/go/trace/logger"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"tideland.dev/go/trace/failure"
+)
+
+//--------------------
+// CONFIGURATION
+//--------------------
+
+// Environment variables overriding the configuration.
+const (
+	EnvLogLevel  = "TRACE_LOG_LEVEL"
+	EnvLogFormat = "TRACE_LOG_FORMAT"
+)
+
+// Formats of the writers writing to streams or files.
+const (
+	FormatText     = "text"
+	FormatJSON     = "json"
+	FormatLogfmt   = "logfmt"
+	FormatConsole  = "console"
+	FormatTemplate = "template"
+)
+
+// Types of the configured writers.
+const (
+	WriterStdout   = "stdout"
+	WriterStderr   = "stderr"
+	WriterFile     = "file"
+	WriterSyslog   = "syslog"
+	WriterShipping = "shipping"
+)
+
+// Config describes the whole setup of a logger. It can be created in
+// code or unmarshalled from JSON. Other formats like YAML are not parsed
+// by this package, but their decoders can fill a Config too. Levels are
+// passed by name, durations like "24h".
+type Config struct {
+	// Level of the logger, by default "info".
+	Level string `json:"level,omitempty"`
+
+	// Format of the stream writers without an own one, by default "text".
+	// Also "json", "logfmt", and "console" are possible.
+	Format string `json:"format,omitempty"`
+
+	// Packages maps package paths to their levels.
+	Packages map[string]string `json:"packages,omitempty"`
+
+	// Locations lists the levels logging the location of the caller,
+	// by default "debug", "critical", and "fatal".
+	Locations []string `json:"locations,omitempty"`
+
+	// Writers of the logger. Multiple ones are combined with a multi
+	// writer. By default the logger writes to stdout.
+	Writers []WriterConfig `json:"writers,omitempty"`
+
+	// Sampling limits the number of similar entries.
+	Sampling *SamplingSettings `json:"sampling,omitempty"`
+}
+
+// WriterConfig describes one writer of the configuration. Only the
+// options of the type are used.
+type WriterConfig struct {
+	// Name of the writer inside a multi writer, by default its type.
+	Name string `json:"name,omitempty"`
+
+	// Type is "stdout", "stderr", "file", "syslog", or "shipping".
+	Type string `json:"type"`
+
+	// Level is the minimum level of entries written by this writer.
+	Level string `json:"level,omitempty"`
+
+	// Format is "text", "json", "logfmt", or "template" for streams and files,
+	// streams also allow "console".
+	Format string `json:"format,omitempty"`
+
+	// Template is the text template of the "template" format.
+	Template string `json:"template,omitempty"`
+
+	// Options of file writers.
+	Filename   string `json:"filename,omitempty"`
+	MaxSize    int64  `json:"max_size,omitempty"`
+	Interval   string `json:"interval,omitempty"`
+	MaxBackups int    `json:"max_backups,omitempty"`
+	Compress   bool   `json:"compress,omitempty"`
+
+	// Options of syslog writers.
+	Network  string `json:"network,omitempty"`
+	Address  string `json:"address,omitempty"`
+	Facility string `json:"facility,omitempty"`
+	AppName  string `json:"app_name,omitempty"`
+	RFC3164  bool   `json:"rfc3164,omitempty"`
+
+	// Options of shipping writers. The interval is shared with
+	// the file writers.
+	URL        string            `json:"url,omitempty"`
+	ShipFormat string            `json:"ship_format,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	BatchSize  int               `json:"batch_size,omitempty"`
+	Gzip       bool              `json:"gzip,omitempty"`
+	SpoolDir   string            `json:"spool_dir,omitempty"`
+}
+
+// SamplingSettings describes the sampling of the configuration.
+type SamplingSettings struct {
+	First    int    `json:"first"`
+	Interval string `json:"interval"`
+}
+
+// syslogFacilities maps the names of syslog facilities.
+var syslogFacilities = map[string]SyslogFacility{
+	"user":     FacilityUser,
+	"mail":     FacilityMail,
+	"daemon":   FacilityDaemon,
+	"auth":     FacilityAuth,
+	"syslog":   FacilitySyslog,
+	"lpr":      FacilityLPR,
+	"news":     FacilityNews,
+	"uucp":     FacilityUUCP,
+	"cron":     FacilityCron,
+	"authpriv": FacilityAuthPriv,
+	"ftp":      FacilityFTP,
+	"local0":   FacilityLocal0,
+	"local1":   FacilityLocal1,
+	"local2":   FacilityLocal2,
+	"local3":   FacilityLocal3,
+	"local4":   FacilityLocal4,
+	"local5":   FacilityLocal5,
+	"local6":   FacilityLocal6,
+	"local7":   FacilityLocal7,
+}
+
+// shippingFormats maps the names of shipping formats.
+var shippingFormats = map[string]ShippingFormat{
+	"json":          ShipJSON,
+	"loki":          ShipLoki,
+	"elasticsearch": ShipElasticsearch,
+}
+
+// ParseConfig unmarshals a configuration from JSON. Parsing YAML or
+// other formats is out of scope, use their own decoders instead.
+func ParseConfig(data []byte) (Config, error) {
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return Config{}, failure.Annotate(err, "invalid logger configuration")
+	}
+	return cfg, nil
+}
+
+// WithEnv returns the configuration with the level and format set by
+// the environment variables TRACE_LOG_LEVEL and TRACE_LOG_FORMAT.
+func (cfg Config) WithEnv() Config {
+	if level := os.Getenv(EnvLogLevel); level != "" {
+		cfg.Level = level
+	}
+	if format := os.Getenv(EnvLogFormat); format != "" {
+		cfg.Format = format
+	}
+	return cfg
+}
+
+// Configure validates the configuration and sets level, package levels,
+// location levels, writer, and sampling of the logger at once. Nothing
+// is changed in case of an invalid configuration. The former writer is
+// returned like by SetWriter() and not closed, so the caller has to
+// close it if needed. A filter set with SetFilter() is kept.
+func (l *Logger) Configure(cfg Config) (Writer, error) {
+	// Validate everything before creating writers.
+	level := LevelInfo
+	if cfg.Level != "" {
+		var err error
+		level, err = ParseLevel(cfg.Level)
+		if err != nil {
+			return nil, failure.Annotate(err, "invalid logger configuration")
+		}
+	}
+	packages := make(map[string]LogLevel, len(cfg.Packages))
+	for pkg, text := range cfg.Packages {
+		plevel, err := ParseLevel(text)
+		if err != nil {
+			return nil, failure.Annotate(err, "invalid level of package %q", pkg)
+		}
+		packages[pkg] = plevel
+	}
+	locationLevels := DefaultLocationLevels
+	if cfg.Locations != nil {
+		locationLevels = 0
+		for _, text := range cfg.Locations {
+			llevel, err := ParseLevel(text)
+			if err != nil {
+				return nil, failure.Annotate(err, "invalid location level")
+			}
+			locationLevels |= MaskOf(llevel)
+		}
+	}
+	switch cfg.Format {
+	case "", FormatText, FormatJSON, FormatLogfmt, FormatConsole:
+	default:
+		return nil, failure.New("invalid logger format %q", cfg.Format)
+	}
+	var sampling *SamplingConfig
+	if cfg.Sampling != nil {
+		interval, err := parseConfigDuration(cfg.Sampling.Interval)
+		if err != nil || cfg.Sampling.First <= 0 || interval <= 0 {
+			return nil, failure.New("invalid sampling of first %d per %q", cfg.Sampling.First, cfg.Sampling.Interval)
+		}
+		sampling = &SamplingConfig{
+			First:    cfg.Sampling.First,
+			Interval: interval,
+		}
+	}
+	wcfgs := append([]WriterConfig{}, cfg.Writers...)
+	if len(wcfgs) == 0 {
+		wcfgs = []WriterConfig{{Type: WriterStdout}}
+	}
+	names := make(map[string]bool, len(wcfgs))
+	for i := range wcfgs {
+		wcfg := &wcfgs[i]
+		if wcfg.Name == "" {
+			wcfg.Name = wcfg.Type
+		}
+		if names[wcfg.Name] {
+			return nil, failure.New("duplicate writer name %q", wcfg.Name)
+		}
+		names[wcfg.Name] = true
+		if wcfg.Format == "" && (wcfg.Type == WriterStdout || wcfg.Type == WriterStderr) {
+			wcfg.Format = cfg.Format
+		}
+		if err := wcfg.validate(); err != nil {
+			return nil, failure.Annotate(err, "invalid writer %q", wcfg.Name)
+		}
+	}
+	// Create the writers.
+	out, err := newConfiguredWriter(wcfgs)
+	if err != nil {
+		return nil, err
+	}
+	var overrides levelOverrides
+	for pkg, plevel := range packages {
+		overrides = overrides.with(LevelOverride{
+			Package: pkg,
+			Level:   plevel,
+		})
+	}
+	var configuredSampler *sampler
+	if sampling != nil {
+		configuredSampler = newSampler(*sampling)
+	}
+	// Finally apply the settings at once.
+	l.backend.mu.Lock()
+	defer l.backend.mu.Unlock()
+	current := unadaptWriter(l.backend.out)
+	l.backend.level = level
+	l.backend.overrides = overrides
+	l.backend.locationLevels = locationLevels
+	l.backend.out = AdaptWriter(out)
+	l.backend.setSampler(configuredSampler)
+	return current, nil
+}
+
+// Configure configures the default logger and returns its former writer.
+func Configure(cfg Config) (Writer, error) {
+	return std.Configure(cfg)
+}
+
+// validate checks the writer configuration.
+func (wcfg *WriterConfig) validate() error {
+	if wcfg.Level != "" {
+		if _, err := ParseLevel(wcfg.Level); err != nil {
+			return err
+		}
+	}
+	if _, err := parseConfigDuration(wcfg.Interval); err != nil {
+		return err
+	}
+	switch wcfg.Type {
+	case WriterStdout, WriterStderr:
+		if wcfg.Format != FormatConsole {
+			if _, err := wcfg.formatter(); err != nil {
+				return err
+			}
+		}
+	case WriterFile:
+		if wcfg.Filename == "" {
+			return failure.New("missing log file name")
+		}
+		if _, err := wcfg.formatter(); err != nil {
+			return err
+		}
+	case WriterSyslog:
+		if wcfg.Facility != "" {
+			if _, ok := syslogFacilities[strings.ToLower(wcfg.Facility)]; !ok {
+				return failure.New("invalid syslog facility %q", wcfg.Facility)
+			}
+		}
+	case WriterShipping:
+		if wcfg.URL == "" {
+			return failure.New("missing shipping URL")
+		}
+		if wcfg.ShipFormat != "" {
+			if _, ok := shippingFormats[strings.ToLower(wcfg.ShipFormat)]; !ok {
+				return failure.New("invalid shipping format %q", wcfg.ShipFormat)
+			}
+		}
+	default:
+		return failure.New("invalid writer type %q", wcfg.Type)
+	}
+	return nil
+}
+
+// newConfiguredWriter creates the validated writers. Multiple ones or
+// ones with an own level are combined with a multi writer.
+func newConfiguredWriter(wcfgs []WriterConfig) (Writer, error) {
+	if len(wcfgs) == 1 && wcfgs[0].Level == "" {
+		return wcfgs[0].newWriter()
+	}
+	targets := make([]Target, 0, len(wcfgs))
+	for _, wcfg := range wcfgs {
+		w, err := wcfg.newWriter()
+		if err != nil {
+			for _, target := range targets {
+				if c, ok := target.Writer.(io.Closer); ok {
+					c.Close()
+				}
+			}
+			return nil, err
+		}
+		level := LevelDebug
+		if wcfg.Level != "" {
+			level, _ = ParseLevel(wcfg.Level)
+		}
+		targets = append(targets, Target{
+			Name:   wcfg.Name,
+			Writer: w,
+			Level:  level,
+		})
+	}
+	return NewMultiWriter(targets...), nil
+}
+
+// newWriter creates the validated writer.
+func (wcfg WriterConfig) newWriter() (Writer, error) {
+	interval, _ := parseConfigDuration(wcfg.Interval)
+	switch wcfg.Type {
+	case WriterFile:
+		formatter, _ := wcfg.formatter()
+		w, err := NewFileWriter(FileWriterConfig{
+			Filename:   wcfg.Filename,
+			MaxSize:    wcfg.MaxSize,
+			Interval:   interval,
+			MaxBackups: wcfg.MaxBackups,
+			Compress:   wcfg.Compress,
+			Formatter:  formatter,
+		})
+		return w, failure.Annotate(err, "cannot create writer %q", wcfg.Name)
+	case WriterSyslog:
+		scfg := SyslogConfig{
+			Network:  wcfg.Network,
+			Address:  wcfg.Address,
+			Facility: syslogFacilities[strings.ToLower(wcfg.Facility)],
+			AppName:  wcfg.AppName,
+		}
+		if wcfg.RFC3164 {
+			scfg.Format = SyslogRFC3164
+		}
+		w, err := NewSyslogWriter(scfg)
+		return w, failure.Annotate(err, "cannot create writer %q", wcfg.Name)
+	case WriterShipping:
+		w, err := NewShippingWriter(ShippingConfig{
+			URL:       wcfg.URL,
+			Format:    shippingFormats[strings.ToLower(wcfg.ShipFormat)],
+			Labels:    wcfg.Labels,
+			BatchSize: wcfg.BatchSize,
+			Interval:  interval,
+			Gzip:      wcfg.Gzip,
+			SpoolDir:  wcfg.SpoolDir,
+		})
+		return w, failure.Annotate(err, "cannot create writer %q", wcfg.Name)
+	}
+	var out io.Writer = os.Stdout
+	if wcfg.Type == WriterStderr {
+		out = os.Stderr
+	}
+	if wcfg.Format == FormatConsole {
+		return NewConsoleWriter(out), nil
+	}
+	formatter, _ := wcfg.formatter()
+	return NewFormatWriter(out, formatter), nil
+}
+
+// formatter creates the formatter of stream and file writers.
+func (wcfg WriterConfig) formatter() (Formatter, error) {
+	switch wcfg.Format {
+	case "", FormatText:
+		return NewTextFormatter(""), nil
+	case FormatJSON:
+		return NewJSONFormatter(), nil
+	case FormatLogfmt:
+		return NewLogfmtFormatter(), nil
+	case FormatTemplate:
+		return NewTemplateFormatter(wcfg.Template)
+	default:
+		return nil, failure.New("invalid format %q", wcfg.Format)
+	}
+}
+
+// parseConfigDuration parses an optional duration.
+func parseConfigDuration(text string) (time.Duration, error) {
+	if text == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(text)
+	if err != nil || d < 0 {
+		return 0, failure.New("invalid duration %q", text)
+	}
+	return d, nil
+}
+
+// EOF
@@ -0,0 +1,187 @@
+// Tideland Go Trace - Logger - Unit Tests
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/trace/logger"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestConfigure tests configuring a logger from JSON.
+func TestConfigure(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	dir := t.TempDir()
+	data := `{
+		"level": "debug",
+		"packages": {"tideland.dev/go/trace/monitor": "error"},
+		"locations": ["info", "error"],
+		"writers": [
+			{"name": "app", "type": "file", "filename": "` + filepath.Join(dir, "app.log") + `"},
+			{"name": "errors", "type": "file", "level": "error", "filename": "` + filepath.Join(dir, "errors.log") + `",
+			 "format": "template", "template": "{{.Level}}: {{.Message}}"}
+		],
+		"sampling": {"first": 2, "interval": "1h"}
+	}`
+	cfg, err := logger.ParseConfig([]byte(data))
+	assert.Nil(err)
+	tw := logger.NewTestWriter()
+	l := logger.New(tw)
+	prev, err := l.Configure(cfg)
+	assert.Nil(err)
+	assert.Equal(prev, tw)
+
+	assert.Equal(l.Level(), logger.LevelDebug)
+	ovs := l.LevelOverrides()
+	assert.Length(ovs, 1)
+	assert.Equal(ovs[0].Package, "tideland.dev/go/trace/monitor")
+	assert.Equal(ovs[0].Level, logger.LevelError)
+	assert.Equal(l.LocationLevels(), logger.MaskOf(logger.LevelInfo, logger.LevelError))
+
+	l.Debug("details")
+	for i := 0; i < 5; i++ {
+		l.Error("failed")
+	}
+	// Reconfiguring returns the former writer to close.
+	prev, err = l.Configure(logger.Config{})
+	assert.Nil(err)
+	mw, ok := prev.(logger.MultiWriter)
+	assert.True(ok)
+	assert.Equal(mw.Levels(), map[string]logger.LogLevel{"app": logger.LevelDebug, "errors": logger.LevelError})
+	assert.Nil(mw.Close())
+
+	app := readLines(assert, filepath.Join(dir, "app.log"))
+	assert.Length(app, 3)
+	assert.Contains("[DEBUG]", app[0])
+	errors := readLines(assert, filepath.Join(dir, "errors.log"))
+	assert.Length(errors, 2)
+	assert.Equal(errors[1], "ERROR: failed")
+}
+
+// TestConfigureEnv tests overriding level and format by environment.
+func TestConfigureEnv(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	t.Setenv(logger.EnvLogLevel, "warn")
+	t.Setenv(logger.EnvLogFormat, "json")
+	l := logger.New(logger.NewTestWriter())
+
+	cfg := logger.Config{
+		Level: "debug",
+		Writers: []logger.WriterConfig{
+			{Type: logger.WriterStderr},
+		},
+	}.WithEnv()
+	assert.Equal(cfg.Level, "warn")
+	assert.Equal(cfg.Format, logger.FormatJSON)
+	_, err := l.Configure(cfg)
+	assert.Nil(err)
+	assert.Equal(l.Level(), logger.LevelWarning)
+	assert.Equal(cfg.Writers[0].Format, "")
+	assert.Equal(cfg.Writers[0].Name, "")
+}
+
+// TestConfigureKeepsFilter tests that configuring the sampling doesn't
+// touch a filter set by the user.
+func TestConfigureKeepsFilter(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	tw := logger.NewTestWriter()
+	l := logger.New(tw)
+	l.SetFilter(func(level logger.LogLevel, msg string) bool {
+		return msg == "secret"
+	})
+
+	cfg, err := logger.ParseConfig([]byte(`{"sampling": {"first": 2, "interval": "1h"}}`))
+	assert.Nil(err)
+	_, err = l.Configure(cfg)
+	assert.Nil(err)
+	tw = logger.NewTestWriter()
+	l.SetWriter(tw)
+	for i := 0; i < 3; i++ {
+		l.Info("secret")
+		l.Info("public")
+	}
+	assert.Length(tw, 2)
+
+	_, err = l.Configure(logger.Config{})
+	assert.Nil(err)
+	tw = logger.NewTestWriter()
+	l.SetWriter(tw)
+	for i := 0; i < 3; i++ {
+		l.Info("secret")
+		l.Info("public")
+	}
+	assert.Length(tw, 3)
+}
+
+// TestConfigureInvalid tests the validation of configurations.
+func TestConfigureInvalid(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	tw := logger.NewTestWriter()
+	l := logger.New(tw)
+
+	tests := []struct {
+		data string
+		msg  string
+	}{
+		{`{"level": "loud"}`, `invalid log level "loud"`},
+		{`{"format": "xml"}`, `invalid logger format "xml"`},
+		{`{"packages": {"foo": "loud"}}`, `invalid level of package "foo"`},
+		{`{"locations": ["debug", "loud"]}`, "invalid location level"},
+		{`{"sampling": {"first": 0, "interval": "1s"}}`, `invalid sampling of first 0 per "1s"`},
+		{`{"writers": [{"type": "pigeon"}]}`, `invalid writer type "pigeon"`},
+		{`{"writers": [{"type": "stdout"}, {"type": "stdout"}]}`, `duplicate writer name "stdout"`},
+		{`{"writers": [{"type": "file"}]}`, "missing log file name"},
+		{`{"writers": [{"type": "file", "filename": "a.log", "format": "console"}]}`, `invalid format "console"`},
+		{`{"writers": [{"type": "stdout", "format": "template", "template": "{{.Level"}]}`, "invalid format template"},
+		{`{"writers": [{"type": "file", "filename": "a.log", "interval": "daily"}]}`, `invalid duration "daily"`},
+		{`{"writers": [{"type": "syslog", "facility": "local9"}]}`, `invalid syslog facility "local9"`},
+		{`{"writers": [{"type": "shipping"}]}`, "missing shipping URL"},
+		{`{"writers": [{"type": "shipping", "url": "http://localhost", "ship_format": "splunk"}]}`, `invalid shipping format "splunk"`},
+		{`{"writers": [{"type": "stdout", "level": "loud"}]}`, `invalid log level "loud"`},
+		{`{"level": 1}`, "invalid logger configuration"},
+	}
+	for _, test := range tests {
+		cfg, err := logger.ParseConfig([]byte(test.data))
+		if err == nil {
+			_, err = l.Configure(cfg)
+		}
+		assert.ErrorContains(err, test.msg)
+	}
+	// Logger is unchanged.
+	assert.Equal(l.Level(), logger.LevelInfo)
+	assert.Equal(l.SetWriter(nil), tw)
+}
+
+//--------------------
+// HELPERS
+//--------------------
+
+// readLines reads the lines of a file.
+func readLines(assert *asserts.Asserts, filename string) []string {
+	f, err := os.Open(filename)
+	assert.Nil(err)
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	assert.Nil(err)
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+// EOF
@@ -0,0 +1,180 @@
+// Tideland Go Trace - Logger - Console Writer
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger // import "tideland.dev/go/trace/logger"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"tideland.dev/go/trace/location"
+)
+
+//--------------------
+// COLORS
+//--------------------
+
+// ANSI escape sequences used by the console writer.
+const (
+	ansiReset   = "\x1b[0m"
+	ansiGrey    = "\x1b[90m"
+	ansiRed     = "\x1b[31m"
+	ansiYellow  = "\x1b[33m"
+	ansiBlue    = "\x1b[34m"
+	ansiMagenta = "\x1b[35m"
+	ansiCyan    = "\x1b[36m"
+	ansiBold    = "\x1b[1m"
+	ansiInverse = "\x1b[7m"
+)
+
+// levelColor maps log levels to the according colors.
+var levelColor = map[LogLevel]string{
+	LevelDebug:    ansiGrey,
+	LevelInfo:     ansiBlue,
+	LevelWarning:  ansiYellow,
+	LevelError:    ansiRed,
+	LevelCritical: ansiBold + ansiMagenta,
+	LevelFatal:    ansiInverse + ansiRed,
+}
+
+// maxLocationWidth limits the alignment of the location column.
+const maxLocationWidth = 40
+
+// useColors checks if colors shall be used for the output. This is
+// the case for terminals if the environment variable NO_COLOR is
+// not set to a non-empty value, see https://no-color.org.
+func useColors(out io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := out.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// ShortLocation returns a short form of a location containing the last
+// part of the package path, the file name, and the line.
+func ShortLocation(loc location.Location) string {
+	if loc.ID == "" {
+		return ""
+	}
+	return path.Base(loc.Package) + "/" + loc.File + ":" + strconv.Itoa(loc.Line)
+}
+
+//--------------------
+// CONSOLE WRITER
+//--------------------
+
+// consoleWriter writes human-friendly and optionally colored
+// entries for local development.
+type consoleWriter struct {
+	mu       sync.Mutex
+	out      io.Writer
+	colors   bool
+	locWidth int
+}
+
+// NewConsoleWriter creates a writer for human-friendly output with
+// aligned columns. Levels are colored if the output is a terminal and
+// the environment variable NO_COLOR is empty or not set.
+func NewConsoleWriter(out io.Writer) Writer {
+	return NewConsoleColorWriter(out, useColors(out))
+}
+
+// NewConsoleColorWriter creates a console writer with explicitly
+// enabled or disabled colors.
+func NewConsoleColorWriter(out io.Writer, colors bool) Writer {
+	return &consoleWriter{
+		out:    out,
+		colors: colors,
+	}
+}
+
+// NewConsoleOutWriter creates a console writer writing to STDOUT.
+func NewConsoleOutWriter() Writer {
+	return NewConsoleWriter(os.Stdout)
+}
+
+// Write implements Writer.
+func (w *consoleWriter) Write(level LogLevel, msg string) error {
+	return w.WriteEntry(Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: msg,
+	})
+}
+
+// WriteEntry implements EntryWriter.
+func (w *consoleWriter) WriteEntry(entry Entry) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var sb strings.Builder
+	// Time and level.
+	w.paint(&sb, ansiGrey, entry.Time.Format("15:04:05.000"))
+	sb.WriteString(" ")
+	w.paint(&sb, levelColor[entry.Level], padRight(levelToText(entry.Level), 8))
+	sb.WriteString(" ")
+	// Location aligned to the widest one so far.
+	loc := ShortLocation(entry.Location)
+	if len(loc) > w.locWidth && len(loc) <= maxLocationWidth {
+		w.locWidth = len(loc)
+	}
+	if w.locWidth > 0 {
+		w.paint(&sb, ansiGrey, padRight(loc, w.locWidth))
+		sb.WriteString(" ")
+	}
+	// Message and fields.
+	if entry.Level >= LevelError {
+		w.paint(&sb, ansiBold, entry.Message)
+	} else {
+		sb.WriteString(entry.Message)
+	}
+	for _, f := range entry.Fields {
+		sb.WriteString("  ")
+		w.paint(&sb, ansiCyan, f.Key+"=")
+		sb.WriteString(quoteValue(f.Value))
+	}
+	sb.WriteString("\n")
+	_, err := io.WriteString(w.out, sb.String())
+	return err
+}
+
+// paint writes the text in the given color if colors are enabled.
+func (w *consoleWriter) paint(sb *strings.Builder, color, text string) {
+	if !w.colors || color == "" {
+		sb.WriteString(text)
+		return
+	}
+	sb.WriteString(color)
+	sb.WriteString(text)
+	sb.WriteString(ansiReset)
+}
+
+// padRight fills the text with spaces up to the given width.
+func padRight(text string, width int) string {
+	if len(text) >= width {
+		return text
+	}
+	return text + strings.Repeat(" ", width-len(text))
+}
+
+// EOF
@@ -0,0 +1,84 @@
+// Tideland Go Trace - Logger - Unit Tests
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/trace/location"
+	"tideland.dev/go/trace/logger"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestConsoleWriter tests the plain output of the console writer.
+func TestConsoleWriter(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	buf := &bytes.Buffer{}
+	l := logger.New(logger.NewConsoleWriter(buf))
+
+	l.Info("user created", "user", "foo", "name", "Mr Foo")
+	l.Warning("disk full")
+	l.Critical("broken")
+	l.Error("failed", "code", 42)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Length(lines, 4)
+	// No colors for non-terminals.
+	assert.False(strings.Contains(buf.String(), "\x1b["))
+	assert.Contains(" INFO     user created  user=foo  name=\"Mr Foo\"", lines[0])
+	assert.Contains(" WARNING  disk full", lines[1])
+	assert.Contains(" CRITICAL logger_test/consolewriter_test.go:", lines[2])
+	// Location column stays aligned after the first location.
+	msgAt := strings.Index(lines[2], "broken")
+	assert.Equal(strings.Index(lines[3], "failed"), msgAt)
+}
+
+// TestConsoleColorWriter tests the colored output of the console writer.
+func TestConsoleColorWriter(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	buf := &bytes.Buffer{}
+	l := logger.New(logger.NewConsoleColorWriter(buf, true))
+	l.SetLevel(logger.LevelDebug)
+
+	l.Warning("disk full", "path", "/var")
+	assert.Contains("\x1b[33mWARNING \x1b[0m", buf.String())
+	assert.Contains("\x1b[36mpath=\x1b[0m/var", buf.String())
+	buf.Reset()
+	l.Error("failed")
+	assert.Contains("\x1b[31mERROR   \x1b[0m", buf.String())
+	buf.Reset()
+	l.Debug("details")
+	assert.Contains("\x1b[90mDEBUG   \x1b[0m", buf.String())
+}
+
+// TestShortLocation tests the shortening of locations.
+func TestShortLocation(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+
+	assert.Equal(logger.ShortLocation(location.Location{}), "")
+	loc := location.Location{
+		ID:      "(tideland.dev/go/trace/logger:logger.go:Debug:42)",
+		Package: "tideland.dev/go/trace/logger",
+		File:    "logger.go",
+		Func:    "Debug",
+		Line:    42,
+	}
+	assert.Equal(logger.ShortLocation(loc), "logger/logger.go:42")
+}
+
+// EOF
@@ -0,0 +1,236 @@
+// Tideland Go Trace - Logger - Context
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger // import "tideland.dev/go/trace/logger"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"time"
+)
+
+//--------------------
+// CONTEXT
+//--------------------
+
+// contextKey describes the type of the context keys.
+type contextKey int
+
+// Context keys for the logger and the metadata.
+const (
+	loggerContextKey contextKey = iota + 1
+	requestIDContextKey
+	traceIDContextKey
+	fieldsContextKey
+)
+
+// Field keys of the metadata taken from a context.
+const (
+	RequestIDKey = "request_id"
+	TraceIDKey   = "trace_id"
+)
+
+// NewContext creates a context containing a logger. All functions
+// of this package treat a nil context like context.Background().
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(orBackground(ctx), loggerContextKey, l)
+}
+
+// WithRequestID creates a context containing a request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(orBackground(ctx), requestIDContextKey, id)
+}
+
+// RequestID retrieves a request ID from a context.
+func RequestID(ctx context.Context) (string, bool) {
+	id, ok := orBackground(ctx).Value(requestIDContextKey).(string)
+	return id, ok
+}
+
+// WithTraceID creates a context containing a trace ID.
+func WithTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(orBackground(ctx), traceIDContextKey, id)
+}
+
+// TraceID retrieves a trace ID from a context.
+func TraceID(ctx context.Context) (string, bool) {
+	id, ok := orBackground(ctx).Value(traceIDContextKey).(string)
+	return id, ok
+}
+
+// WithFields creates a context containing the passed fields in addition
+// to those of the parent context. They are passed as alternating keys
+// and values or as Field.
+func WithFields(ctx context.Context, fields ...interface{}) context.Context {
+	ctx = orBackground(ctx)
+	pfs, _ := ctx.Value(fieldsContextKey).(Fields)
+	cfs := make(Fields, 0, len(pfs)+len(fields)/2)
+	cfs = append(cfs, pfs...)
+	cfs = append(cfs, toFields(fields...)...)
+	return context.WithValue(ctx, fieldsContextKey, cfs)
+}
+
+// FromContext retrieves the logger from a context, or the default logger
+// if it contains none. The request ID, trace ID, and the fields stored
+// in the context are added to all entries of the returned logger.
+func FromContext(ctx context.Context) *Logger {
+	return loggerOf(ctx).WithContext(ctx)
+}
+
+// loggerOf retrieves the logger from a context, or the default logger
+// if it contains none, without adding the metadata.
+func loggerOf(ctx context.Context) *Logger {
+	l, ok := orBackground(ctx).Value(loggerContextKey).(*Logger)
+	if !ok || l == nil {
+		return std
+	}
+	return l
+}
+
+// orBackground returns the passed context or the background
+// context if it is nil.
+func orBackground(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
+
+// WithContext returns a child logger adding the request ID, trace ID,
+// and fields stored in the context to all entries. If the context
+// contains none the logger itself is returned.
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	cfs := contextFields(ctx)
+	if len(cfs) == 0 {
+		return l
+	}
+	return l.With(cfs)
+}
+
+// contextFields returns the metadata of the context as fields.
+func contextFields(ctx context.Context) Fields {
+	if ctx == nil {
+		return nil
+	}
+	var cfs Fields
+	if id, ok := RequestID(ctx); ok {
+		cfs = append(cfs, F(RequestIDKey, id))
+	}
+	if id, ok := TraceID(ctx); ok {
+		cfs = append(cfs, F(TraceIDKey, id))
+	}
+	if fs, ok := ctx.Value(fieldsContextKey).(Fields); ok {
+		cfs = append(cfs, fs...)
+	}
+	return cfs
+}
+
+//--------------------
+// CONTEXT LOGGING
+//--------------------
+
+// logContext checks the level before adding the metadata of the context
+// and logging the message with its fields. So disabled levels don't
+// allocate a child logger.
+func (l *Logger) logContext(ctx context.Context, level LogLevel, offset int, msg string, fields []interface{}) {
+	loc, pc, fr, ok := l.backend.admit(level, offset+l.skip+1)
+	if !ok && fr == nil {
+		// Passed level is too low.
+		return
+	}
+	l.emit(Entry{
+		Time:     time.Now(),
+		Level:    level,
+		Location: loc,
+		Message:  msg,
+		Fields:   l.WithContext(ctx).entryFields(fields),
+		pc:       pc,
+		template: msg,
+	}, fr, ok)
+}
+
+// DebugContext logs a message with structured fields at debug level.
+// The metadata of the context is added.
+func (l *Logger) DebugContext(ctx context.Context, msg string, fields ...interface{}) {
+	l.logContext(ctx, LevelDebug, 1, msg, fields)
+}
+
+// InfoContext logs a message with structured fields at info level.
+// The metadata of the context is added.
+func (l *Logger) InfoContext(ctx context.Context, msg string, fields ...interface{}) {
+	l.logContext(ctx, LevelInfo, 1, msg, fields)
+}
+
+// WarningContext logs a message with structured fields at warning level.
+// The metadata of the context is added.
+func (l *Logger) WarningContext(ctx context.Context, msg string, fields ...interface{}) {
+	l.logContext(ctx, LevelWarning, 1, msg, fields)
+}
+
+// ErrorContext logs a message with structured fields at error level.
+// The metadata of the context is added.
+func (l *Logger) ErrorContext(ctx context.Context, msg string, fields ...interface{}) {
+	l.logContext(ctx, LevelError, 1, msg, fields)
+}
+
+// CriticalContext logs a message with structured fields at critical level.
+// The metadata of the context is added.
+func (l *Logger) CriticalContext(ctx context.Context, msg string, fields ...interface{}) {
+	l.logContext(ctx, LevelCritical, 1, msg, fields)
+}
+
+// FatalContext logs a message with structured fields at fatal level.
+// The metadata of the context is added. Afterwards the fatal exiter
+// function is called.
+func (l *Logger) FatalContext(ctx context.Context, msg string, fields ...interface{}) {
+	l.logContext(ctx, LevelFatal, 1, msg, fields)
+	l.backend.fatal()
+}
+
+// DebugContext logs a message with structured fields at debug level
+// using the logger and the metadata of the context.
+func DebugContext(ctx context.Context, msg string, fields ...interface{}) {
+	loggerOf(ctx).logContext(ctx, LevelDebug, 1, msg, fields)
+}
+
+// InfoContext logs a message with structured fields at info level
+// using the logger and the metadata of the context.
+func InfoContext(ctx context.Context, msg string, fields ...interface{}) {
+	loggerOf(ctx).logContext(ctx, LevelInfo, 1, msg, fields)
+}
+
+// WarningContext logs a message with structured fields at warning level
+// using the logger and the metadata of the context.
+func WarningContext(ctx context.Context, msg string, fields ...interface{}) {
+	loggerOf(ctx).logContext(ctx, LevelWarning, 1, msg, fields)
+}
+
+// ErrorContext logs a message with structured fields at error level
+// using the logger and the metadata of the context.
+func ErrorContext(ctx context.Context, msg string, fields ...interface{}) {
+	loggerOf(ctx).logContext(ctx, LevelError, 1, msg, fields)
+}
+
+// CriticalContext logs a message with structured fields at critical level
+// using the logger and the metadata of the context.
+func CriticalContext(ctx context.Context, msg string, fields ...interface{}) {
+	loggerOf(ctx).logContext(ctx, LevelCritical, 1, msg, fields)
+}
+
+// FatalContext logs a message with structured fields at fatal level
+// using the logger and the metadata of the context. Afterwards the
+// fatal exiter function is called.
+func FatalContext(ctx context.Context, msg string, fields ...interface{}) {
+	l := loggerOf(ctx)
+	l.logContext(ctx, LevelFatal, 1, msg, fields)
+	l.backend.fatal()
+}
+
+// EOF
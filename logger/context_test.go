@@ -0,0 +1,119 @@
+// Tideland Go Trace - Logger - Unit Tests
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/trace/logger"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestContextLogging tests logging with metadata from a context.
+func TestContextLogging(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	tw := logger.NewTestWriter()
+	l := logger.New(tw)
+
+	ctx := context.Background()
+	ctx = logger.NewContext(ctx, l)
+	ctx = logger.WithRequestID(ctx, "r-1")
+	ctx = logger.WithTraceID(ctx, "t-2")
+	ctx = logger.WithFields(ctx, "user", "foo")
+	ctx = logger.WithFields(ctx, logger.F("tenant", "acme"))
+
+	id, ok := logger.RequestID(ctx)
+	assert.True(ok)
+	assert.Equal(id, "r-1")
+
+	logger.InfoContext(ctx, "handled", "status", 200)
+	logger.FromContext(ctx).Named("db").Warningf("slow")
+	l.ErrorContext(logger.WithRequestID(context.Background(), "r-3"), "failed")
+	logger.DebugContext(ctx, "dropped")
+
+	es := tw.Entries()
+	assert.Length(es, 3)
+	assert.Contains("handled request_id=r-1 trace_id=t-2 user=foo tenant=acme status=200", es[0])
+	assert.Contains("slow logger=db request_id=r-1 trace_id=t-2 user=foo tenant=acme", es[1])
+	assert.Contains("[ERROR] failed request_id=r-3", es[2])
+
+	// Without logger in the context the default one is used.
+	cw := logger.SetWriter(tw)
+	defer logger.SetWriter(cw)
+	tw.Reset()
+	logger.WarningContext(logger.WithTraceID(context.Background(), "t-4"), "default")
+	assert.Length(tw, 1)
+	assert.Contains("default trace_id=t-4", tw.Entries()[0])
+}
+
+// TestContextSlog tests the metadata from a context with slog.
+func TestContextSlog(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	tw := logger.NewTestWriter()
+	sl := slog.New(logger.NewSlogHandler(tw, logger.LevelInfo))
+
+	ctx := logger.WithRequestID(context.Background(), "r-1")
+	sl.InfoContext(ctx, "handled", "status", 200)
+
+	assert.Length(tw, 1)
+	assert.Contains("handled request_id=r-1 status=200", tw.Entries()[0])
+}
+
+// TestContextDisabledLevel tests that logging with a context at a
+// disabled level doesn't allocate.
+func TestContextDisabledLevel(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	tw := logger.NewTestWriter()
+	l := logger.New(tw)
+	ctx := logger.WithRequestID(context.Background(), "r-1")
+	ctx = logger.WithFields(ctx, "user", "alice")
+
+	allocs := testing.AllocsPerRun(100, func() {
+		l.DebugContext(ctx, "details")
+	})
+	assert.Equal(allocs, 0.0)
+	assert.Length(tw, 0)
+
+	l.InfoContext(ctx, "request")
+	assert.Length(tw, 1)
+	assert.Contains("request request_id=r-1 user=alice", tw.Entries()[0])
+}
+
+// TestContextNil tests that a nil context is treated like the
+// background context.
+func TestContextNil(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	tw := logger.NewTestWriter()
+	cw := logger.SetWriter(tw)
+	defer logger.SetWriter(cw)
+
+	var ctx context.Context
+	_, ok := logger.RequestID(ctx)
+	assert.False(ok)
+	_, ok = logger.TraceID(ctx)
+	assert.False(ok)
+	assert.Equal(logger.FromContext(ctx), logger.FromContext(context.Background()))
+	logger.InfoContext(logger.WithFields(ctx, "user", "alice"), "fields")
+	logger.New(tw).InfoContext(ctx, "none")
+	es := tw.Entries()
+	assert.Length(es, 2)
+	assert.Contains("fields user=alice", es[0])
+	assert.Contains("none", es[1])
+}
+
+// EOF
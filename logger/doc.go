@@ -17,20 +17,180 @@
 //     es := w.Entries()
 //     w.Reset()
 //
+// A capture writer keeps the structured entries instead and allows
+// assertions on them. logger.Capture() sets it for the duration of a test.
+//
+//     cw := logger.Capture(t, nil)
+//     ...
+//     cw.AssertLogged(t, logger.LevelError, "connection .* refused")
+//
 // The default logger writes to stdout, others can be instantiated with
-// any io.Writer. logger.NewGoWriter() returns a writer using the standard
-// Go logging implementation and logger.NewSysWriter() returs a writer
-// based on the system log.
+// any io.Writer. logger.NewJSONWriter() writes one JSON object per entry,
+// logger.NewGoWriter() returns a writer using the standard
+// Go logging implementation, logger.NewGoFormatWriter() does the same
+// with a Formatter, and logger.NewSysWriter() returs a writer
+// based on the local system log. logger.NewSyslogWriter() formats the
+// entries according to RFC 5424 or RFC 3164 itself and sends them to the
+// local daemon or via UDP, TCP, or TLS to a remote collector.
 //
 // The levels are Debug, Info, Warning, Error, Critical, and Fatal. Here
-// logger.Debugf() also logs information about file name, function
-// name, and line number while log.Fatalf() may end the program
-// depending on the set FatalExiterFunc.
+// log.Fatalf() may end the program depending on the set FatalExiterFunc.
+//
+// Entries of debug, critical, and fatal level contain the location of the
+// caller with package, file, function name, and line number. The levels
+// can be chosen with logger.SetLocationLevels(). Helper functions logging
+// on behalf of their callers use a logger created with WithCallerSkip()
+// to report the locations of those callers.
+//
+//     logger.SetLocationLevels(logger.AllLevels)
+//     ...
+//     func logFailure(err error) {
+//         logger.Default().WithCallerSkip(1).Error("operation failed", "err", err)
+//     }
+//
+// Beside the formatting functions like logger.Infof() the functions
+// logger.Debug(), logger.Info() etc. log a message together with
+// structured fields, passed as alternating keys and values.
+//
+//     logger.Info("user created", "user", id, "tenant", tenant)
+//
+// Writers implementing the EntryWriter interface receive these fields
+// as typed key/value pairs, all others get them rendered as text.
+//
+// The package functions use a default logger. Additional and independent
+// instances with own level, writer, filter, and fatal exiter are created
+// with logger.New(). Child loggers derived with With() and Named() share
+// the settings of their parent but add own fields.
+//
+//     dbl := logger.New(w).Named("db").With("tenant", tenant)
+//     dbl.Infof("connected to %q", dsn)
+//
+// Formatting is independent of the destination. A Formatter like the ones
+// created by logger.NewTextFormatter(), logger.NewJSONFormatter(),
+// logger.NewGELFFormatter(), or logger.NewCEFFormatter() can be combined
+// with any io.Writer by logger.NewFormatWriter() or set for a file writer.
+// logger.NewTemplateFormatter() allows to match legacy formats exactly.
+//
+//     f, err := logger.NewTemplateFormatter(`{{.Time.Format "Jan _2 15:04:05"}} {{.Level}} {{short .Location}} {{.Message}} {{.Fields}}`)
+//     ...
+//     w := logger.NewFormatWriter(conn, f)
+//
+// logger.NewLogfmtWriter() writes entries as logfmt lines like
+//
+//     ts=2020-05-01T12:30:45Z level=info loc=app:main.go:main:42 msg="user created" user=foo
+//
+// logger.ParseLogfmt() and logger.ReadLogfmt() turn such lines back
+// into entries, e.g. to check the output of programs in tests.
+//
+// logger.NewFileWriter() creates a writer to a file rotating by size
+// and/or time, keeping a number of optionally compressed backups.
+//
+// logger.NewShippingWriter() posts batches of entries to HTTP ingestion
+// endpoints like the Loki push API, the Elasticsearch bulk API, or as
+// generic JSON array. Failed requests are retried with backoff and can
+// be spooled to disk during outages.
+//
+// logger.NewMultiWriter() dispatches each entry to multiple targets, each
+// with an own minimum level and optional filter, e.g. all entries to a file
+// but only warnings and above to the syslog.
+// Errors of the writers, like the collected ones of a multi writer, are
+// passed to the handler set with logger.SetErrorHandler(). By default
+// they are written to stderr.
+//
+// To avoid floods of similar entries, e.g. inside of hot loops, sampling
+// can be set with logger.SetSampling(). Only the first entries with the
+// same level, call site, and message template are written per interval.
+// A summary of the suppressed ones is written once per interval. A writer can be wrapped
+// by logger.NewSamplingWriter() for the same. logger.NewSamplingFilter()
+// provides a filter function comparing only level and text.
+//
+// Slow writers can be wrapped by logger.NewAsyncWriter(). It queues the
+// entries and writes them in the background. The policy for a full queue
+// can be chosen. Before calling the fatal exiter the queue is flushed.
+//
+// A flight recorder set with logger.SetFlightRecorder() keeps the entries
+// below the level of the logger per goroutine or context in memory. When
+// an error is logged in the same goroutine or context the preceding debug
+// entries are written before it.
+//
+// logger.NewRingWriter() keeps the last entries in memory, limited by
+// number or size. The handler created with logger.NewTailHandler()
+// returns them filtered by level and text as JSON, or streams them
+// together with the following ones as Server-Sent Events.
+//
+// Request metadata can be stored in a context with logger.WithRequestID(),
+// logger.WithTraceID(), and logger.WithFields(). logger.FromContext()
+// returns the logger stored with logger.NewContext(), or the default one,
+// adding this metadata to all entries. logger.InfoContext() and the other
+// context functions do the same for single entries.
+//
+// Code using the standard log/slog package can write into the writers
+// of this package with a handler created by logger.NewSlogHandler(). The
+// other way around logger.NewSlogWriter() forwards entries to any
+// slog.Handler.
+//
+// For local development logger.NewConsoleWriter() writes aligned columns
+// with short locations and colored levels. Colors are disabled if the
+// output is no terminal or the environment variable NO_COLOR is set to
+// a non-empty value.
+//
+// Levels can be parsed with logger.ParseLevel(), also accepting syslog
+// style aliases like "warn" or "crit". A LogLevel implements fmt.Stringer,
+// the text marshalling interfaces, and flag.Value.
+//
+// The whole setup can be described declaratively, e.g. as JSON, and
+// applied with logger.Configure(). It returns the former writer, which
+// has to be closed by the caller if needed. The environment variables
+// TRACE_LOG_LEVEL and TRACE_LOG_FORMAT override level and format.
+//
+//     cfg, err := logger.ParseConfig(data)
+//     ...
+//     former, err := logger.Configure(cfg.WithEnv())
 //
 // Changes to the standard behavior can be made with logger.SetLevel()
 // and logger.SetFatalExiter(). Own logger backends and exiter can be
 // defined. Additionally a filter function allows to drill down the
 // logged entries.
+//
+// Secrets can be masked before any writer sees the entries. The
+// redaction set with logger.SetRedaction() replaces the values of
+// configured fields, matches of patterns in messages and values, and
+// values implementing the Redactor interface, also when passed as
+// arguments of formatted messages.
+//
+//     logger.SetRedaction(logger.DefaultRedactionConfig())
+//
+// Hooks registered with logger.AddHook() are called for the written
+// entries with a level selected by their mask, e.g. to count entries
+// with a monitor or to send critical ones to an alert channel. They run
+// without holding any lock of the logger.
+//
+//     logger.AddHook("alert", logger.MaskFrom(logger.LevelCritical), logger.NewChannelHook(alertc))
+//
+// In case of a fatal entry the shutdown hooks registered with
+// logger.AddShutdownHook() are called in order within the shutdown
+// timeout. Afterwards the writer is flushed and closed if it, or a writer
+// wrapped by the asynchronous, redacting, or sampling writer, implements
+// Flusher or io.Closer before the fatal exiter is called. Errors are passed
+// to the error handler. A closed writer is replaced by a standard writer to
+// stderr, noted there by a warning. The exit code can be set
+// with logger.NewOSFatalExiter().
+//
+//     logger.AddShutdownHook("db", func(ctx context.Context) error {
+//         return db.Close()
+//     })
+//     logger.SetFatalExiter(logger.NewOSFatalExiter(2))
+//
+// The level can be overridden for single packages, files, or functions
+// at runtime. Here the most specific override matching the location of
+// the caller wins.
+//
+//     logger.SetPackageLevel("tideland.dev/go/trace/monitor", logger.LevelDebug)
+//
+// The handler created with logger.NewHandler() allows to read the levels
+// and overrides of a logger with GET and to change them with PUT or POST,
+// optionally only for a given duration. Levels per writer target are
+// found in multi writers also if wrapped by other writers.
 package logger // import "tideland.dev/go/trace/logger"
 
 // EOF
@@ -0,0 +1,203 @@
+// Tideland Go Trace - Logger - Entries
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger // import "tideland.dev/go/trace/logger"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"tideland.dev/go/trace/location"
+)
+
+//--------------------
+// FIELDS
+//--------------------
+
+// badKey is used as key for values passed without a valid key.
+const badKey = "!BADKEY"
+
+// Field is one typed key/value pair of a structured log entry.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F is a convenience function to create a field.
+func F(key string, value interface{}) Field {
+	return Field{
+		Key:   key,
+		Value: value,
+	}
+}
+
+// String implements the fmt.Stringer interface.
+func (f Field) String() string {
+	return f.Key + "=" + quoteValue(f.Value)
+}
+
+// Fields contains the ordered fields of a log entry.
+type Fields []Field
+
+// String implements the fmt.Stringer interface.
+func (fs Fields) String() string {
+	parts := make([]string, len(fs))
+	for i, f := range fs {
+		parts[i] = f.String()
+	}
+	return strings.Join(parts, " ")
+}
+
+// toFields converts alternating keys and values into fields. Arguments
+// already being a Field are taken as they are. Values without a string
+// key are stored with the key "!BADKEY".
+func toFields(args ...interface{}) Fields {
+	if len(args) == 0 {
+		return nil
+	}
+	fs := make(Fields, 0, len(args)/2+1)
+	for len(args) > 0 {
+		switch a := args[0].(type) {
+		case Field:
+			fs = append(fs, a)
+			args = args[1:]
+		case Fields:
+			fs = append(fs, a...)
+			args = args[1:]
+		case string:
+			if len(args) == 1 {
+				fs = append(fs, F(badKey, a))
+				args = args[1:]
+				continue
+			}
+			fs = append(fs, F(a, args[1]))
+			args = args[2:]
+		default:
+			fs = append(fs, F(badKey, a))
+			args = args[1:]
+		}
+	}
+	return fs
+}
+
+// quoteValue renders a field value and quotes it if needed.
+func quoteValue(value interface{}) string {
+	s := valueText(value)
+	if s == "" || strings.ContainsAny(s, " =\"\t\r\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// valueText renders a field value without quoting.
+func valueText(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+//--------------------
+// ENTRY
+//--------------------
+
+// Entry contains all information of one log entry.
+type Entry struct {
+	Time     time.Time
+	Level    LogLevel
+	Location location.Location
+	Message  string
+	Fields   Fields
+
+	// pc and template identify the call site and the unformatted
+	// message for the sampling of similar entries.
+	pc       uintptr
+	template string
+}
+
+// Text returns the entry as flat text containing the location ID,
+// if set, the message, and the fields.
+func (e Entry) Text() string {
+	var sb strings.Builder
+	if e.Location.ID != "" {
+		sb.WriteString(e.Location.ID)
+		sb.WriteString(" ")
+	}
+	sb.WriteString(e.Message)
+	if len(e.Fields) > 0 {
+		sb.WriteString(" ")
+		sb.WriteString(e.Fields.String())
+	}
+	return sb.String()
+}
+
+// Field returns the value of the first field with the given key.
+func (e Entry) Field(key string) (interface{}, bool) {
+	for _, f := range e.Fields {
+		if f.Key == key {
+			return f.Value, true
+		}
+	}
+	return nil, false
+}
+
+//--------------------
+// ENTRY WRITER
+//--------------------
+
+// EntryWriter extends the Writer interface for writers able to render
+// the structured entries natively.
+type EntryWriter interface {
+	Writer
+
+	// WriteEntry writes the given entry.
+	WriteEntry(entry Entry) error
+}
+
+// writerAdapter lets a simple Writer act as EntryWriter. Entries are
+// passed as flat text.
+type writerAdapter struct {
+	Writer
+}
+
+// AdaptWriter returns the passed writer if it already is an EntryWriter,
+// otherwise an adapter writing the entries as flat text.
+func AdaptWriter(w Writer) EntryWriter {
+	if ew, ok := w.(EntryWriter); ok {
+		return ew
+	}
+	return &writerAdapter{
+		Writer: w,
+	}
+}
+
+// WriteEntry implements EntryWriter.
+func (wa *writerAdapter) WriteEntry(entry Entry) error {
+	return wa.Writer.Write(entry.Level, entry.Text())
+}
+
+// unadaptWriter returns the original writer if the passed one is an adapter.
+func unadaptWriter(ew EntryWriter) Writer {
+	if wa, ok := ew.(*writerAdapter); ok {
+		return wa.Writer
+	}
+	return ew
+}
+
+// EOF
@@ -0,0 +1,366 @@
+// Tideland Go Trace - Logger - Rotating File Writer
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger // import "tideland.dev/go/trace/logger"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"tideland.dev/go/trace/failure"
+)
+
+//--------------------
+// FILE WRITER
+//--------------------
+
+// backupTimeFormat is used for the suffix of rotated files.
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// FileWriterConfig contains the configuration of a rotating file writer.
+type FileWriterConfig struct {
+	// Filename is the path of the log file.
+	Filename string
+
+	// MaxSize is the size in bytes after which the file is rotated.
+	// Zero means no rotation by size.
+	MaxSize int64
+
+	// Interval is the duration after which the file is rotated. Zero
+	// means no rotation by time.
+	Interval time.Duration
+
+	// MaxBackups is the number of rotated files to keep. Zero means
+	// keeping all of them.
+	MaxBackups int
+
+	// Compress controls if rotated files are compressed with gzip.
+	Compress bool
+
+	// ReopenOnHUP lets the writer reopen the file when the process
+	// receives a SIGHUP, e.g. after an external rotation. It has no
+	// effect on platforms without SIGHUP like js, wasip1, and Plan9.
+	ReopenOnHUP bool
+
+	// TimeFormat is the format of the timestamps, by default the
+	// one of the standard writer.
+	TimeFormat string
+
+	// Formatter renders the entries. By default it is a text formatter
+	// using TimeFormat.
+	Formatter Formatter
+}
+
+// FileWriter is a writer to a file rotating by size and/or time.
+type FileWriter interface {
+	EntryWriter
+
+	// Rotate rotates the log file immediately.
+	Rotate() error
+
+	// Reopen closes and reopens the log file.
+	Reopen() error
+
+	// Close stops the writer and closes the log file.
+	Close() error
+}
+
+// fileWriter implements FileWriter.
+type fileWriter struct {
+	mu       sync.Mutex
+	cfg      FileWriterConfig
+	file     *os.File
+	size     int64
+	rotateAt time.Time
+	backupMu sync.Mutex
+	backupWG sync.WaitGroup
+	hupc     chan os.Signal
+	donec    chan struct{}
+	closed   bool
+}
+
+// NewFileWriter creates a writer to the configured file. It is
+// created if needed, otherwise entries are appended.
+func NewFileWriter(cfg FileWriterConfig) (FileWriter, error) {
+	if cfg.Filename == "" {
+		return nil, failure.New("missing log file name")
+	}
+	if cfg.MaxSize < 0 || cfg.Interval < 0 || cfg.MaxBackups < 0 {
+		return nil, failure.New("invalid negative rotation setting")
+	}
+	if cfg.TimeFormat == "" {
+		cfg.TimeFormat = defaultTimeFormat
+	}
+	if cfg.Formatter == nil {
+		cfg.Formatter = NewTextFormatter(cfg.TimeFormat)
+	}
+	w := &fileWriter{
+		cfg:   cfg,
+		donec: make(chan struct{}),
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	if cfg.ReopenOnHUP {
+		w.hupc = make(chan os.Signal, 1)
+		notifyHUP(w.hupc)
+		go w.watchHUP()
+	}
+	return w, nil
+}
+
+// Write implements Writer.
+func (w *fileWriter) Write(level LogLevel, msg string) error {
+	return w.WriteEntry(Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: msg,
+	})
+}
+
+// WriteEntry implements EntryWriter.
+func (w *fileWriter) WriteEntry(entry Entry) error {
+	line, err := w.cfg.Formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return failure.New("log file %q is closed", w.cfg.Filename)
+	}
+	if w.needsRotation(int64(len(line))) {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := w.file.Write(line)
+	w.size += int64(n)
+	return err
+}
+
+// Rotate implements FileWriter.
+func (w *fileWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return failure.New("log file %q is closed", w.cfg.Filename)
+	}
+	return w.rotate()
+}
+
+// Reopen implements FileWriter. It also recovers a writer whose file
+// could not be opened again during a rotation.
+func (w *fileWriter) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return failure.New("log file %q is closed", w.cfg.Filename)
+	}
+	if w.file != nil {
+		err := w.file.Close()
+		w.file = nil
+		if err != nil {
+			return failure.Annotate(err, "cannot close log file %q", w.cfg.Filename)
+		}
+	}
+	return w.open()
+}
+
+// Close implements FileWriter.
+func (w *fileWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if w.hupc != nil {
+		stopHUP(w.hupc)
+	}
+	close(w.donec)
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.backupWG.Wait()
+	return failure.Annotate(err, "cannot close log file %q", w.cfg.Filename)
+}
+
+// watchHUP reopens the file for each received SIGHUP.
+func (w *fileWriter) watchHUP() {
+	for {
+		select {
+		case <-w.donec:
+			return
+		case <-w.hupc:
+			_ = w.Reopen()
+		}
+	}
+}
+
+// needsRotation checks if writing the given number of bytes leads to
+// a rotation.
+func (w *fileWriter) needsRotation(n int64) bool {
+	if w.cfg.MaxSize > 0 && w.size > 0 && w.size+n > w.cfg.MaxSize {
+		return true
+	}
+	return w.cfg.Interval > 0 && !time.Now().Before(w.rotateAt)
+}
+
+// open opens the log file for appending.
+func (w *fileWriter) open() error {
+	file, err := os.OpenFile(w.cfg.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return failure.Annotate(err, "cannot open log file %q", w.cfg.Filename)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return failure.Annotate(err, "cannot stat log file %q", w.cfg.Filename)
+	}
+	w.file = file
+	w.size = info.Size()
+	w.rotateAt = time.Now().Add(w.cfg.Interval)
+	return nil
+}
+
+// rotate renames the current file into a backup, opens a new one, and
+// starts the compression and removal of old backups in the background.
+func (w *fileWriter) rotate() error {
+	err := w.file.Close()
+	w.file = nil
+	if err != nil {
+		return failure.Annotate(err, "cannot close log file %q", w.cfg.Filename)
+	}
+	backup := w.backupName()
+	if err := os.Rename(w.cfg.Filename, backup); err != nil {
+		// Continue with the current file.
+		return failure.Collect(failure.Annotate(err, "cannot rename log file %q", w.cfg.Filename), w.open())
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+	w.backupWG.Add(1)
+	go w.processBackups(backup)
+	return nil
+}
+
+// backupName returns a not yet existing name for a backup.
+func (w *fileWriter) backupName() string {
+	base := w.cfg.Filename + "." + time.Now().Format(backupTimeFormat)
+	name := base
+	for i := 1; ; i++ {
+		_, errPlain := os.Stat(name)
+		_, errGzip := os.Stat(name + ".gz")
+		if os.IsNotExist(errPlain) && os.IsNotExist(errGzip) {
+			return name
+		}
+		name = base + "-" + strconv.Itoa(i)
+	}
+}
+
+// processBackups compresses the new backup if configured and removes
+// the oldest ones exceeding the maximum number.
+func (w *fileWriter) processBackups(backup string) {
+	defer w.backupWG.Done()
+	w.backupMu.Lock()
+	defer w.backupMu.Unlock()
+	if w.cfg.Compress {
+		_ = compressFile(backup)
+	}
+	if w.cfg.MaxBackups == 0 {
+		return
+	}
+	backups, err := filepath.Glob(escapeGlob(w.cfg.Filename) + ".[0-9][0-9][0-9][0-9]-*")
+	if err != nil {
+		return
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		ti, ni := w.backupOrder(backups[i])
+		tj, nj := w.backupOrder(backups[j])
+		if ti != tj {
+			return ti < tj
+		}
+		return ni < nj
+	})
+	for len(backups) > w.cfg.MaxBackups {
+		_ = os.Remove(backups[0])
+		backups = backups[1:]
+	}
+}
+
+// backupOrder returns the timestamp and the numeric suffix of a backup
+// name for sorting. Backups without suffix have the number zero.
+func (w *fileWriter) backupOrder(backup string) (string, int) {
+	suffix := strings.TrimSuffix(strings.TrimPrefix(backup, w.cfg.Filename+"."), ".gz")
+	if len(suffix) <= len(backupTimeFormat) {
+		return suffix, 0
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(suffix[len(backupTimeFormat):], "-"))
+	if err != nil {
+		return suffix, 0
+	}
+	return suffix[:len(backupTimeFormat)], n
+}
+
+// escapeGlob quotes the meta characters of a file name for filepath.Glob.
+func escapeGlob(name string) string {
+	var sb strings.Builder
+	for _, r := range name {
+		switch {
+		case r == '*' || r == '?' || r == '[':
+			sb.WriteRune('[')
+			sb.WriteRune(r)
+			sb.WriteRune(']')
+		case r == '\\' && runtime.GOOS != "windows":
+			sb.WriteString(`\\`)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// compressFile compresses the named file with gzip and removes
+// the original.
+func compressFile(name string) error {
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	gzw := gzip.NewWriter(out)
+	_, err = io.Copy(gzw, in)
+	err = failure.First(err, gzw.Close())
+	err = failure.First(err, out.Close())
+	if err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+	in.Close()
+	return os.Remove(name)
+}
+
+// EOF
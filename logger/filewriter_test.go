@@ -0,0 +1,167 @@
+// Tideland Go Trace - Logger - Unit Tests
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/trace/logger"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestFileWriterSize tests the rotation by size including
+// the limit of backups.
+func TestFileWriterSize(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "test.log")
+	fw, err := logger.NewFileWriter(logger.FileWriterConfig{
+		Filename:   fn,
+		MaxSize:    100,
+		MaxBackups: 2,
+	})
+	assert.Nil(err)
+	l := logger.New(fw)
+
+	for i := 0; i < 10; i++ {
+		l.Infof("entry number %d with some padding text", i)
+	}
+	assert.Nil(fw.Close())
+
+	backups, err := filepath.Glob(fn + ".*")
+	assert.Nil(err)
+	assert.Length(backups, 2)
+	data, err := os.ReadFile(fn)
+	assert.Nil(err)
+	assert.Contains("entry number 9", string(data))
+	assert.True(len(data) <= 100)
+
+	err = fw.WriteEntry(logger.Entry{Message: "closed"})
+	assert.ErrorContains(err, "is closed")
+}
+
+// TestFileWriterBackupOrder tests keeping the newest backups of many
+// rotations in a short time and of a name containing glob meta characters.
+func TestFileWriterBackupOrder(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "app[1].log")
+	fw, err := logger.NewFileWriter(logger.FileWriterConfig{
+		Filename:   fn,
+		MaxBackups: 3,
+	})
+	assert.Nil(err)
+	l := logger.New(fw)
+
+	for i := 0; i < 12; i++ {
+		l.Infof("entry %d", i)
+		assert.Nil(fw.Rotate())
+	}
+	assert.Nil(fw.Close())
+
+	entries, err := os.ReadDir(dir)
+	assert.Nil(err)
+	var kept []string
+	for _, entry := range entries {
+		if entry.Name() == "app[1].log" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		assert.Nil(err)
+		words := strings.Fields(string(data))
+		kept = append(kept, words[len(words)-1])
+	}
+	sort.Strings(kept)
+	assert.Equal(kept, []string{"10", "11", "9"})
+}
+
+// TestFileWriterCompress tests the explicit rotation with compression.
+func TestFileWriterCompress(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "test.log")
+	fw, err := logger.NewFileWriter(logger.FileWriterConfig{
+		Filename: fn,
+		Compress: true,
+	})
+	assert.Nil(err)
+	l := logger.New(fw)
+
+	l.Info("before rotation")
+	assert.Nil(fw.Rotate())
+	l.Info("after rotation")
+	assert.Nil(fw.Close())
+
+	backups, err := filepath.Glob(fn + ".*.gz")
+	assert.Nil(err)
+	assert.Length(backups, 1)
+	f, err := os.Open(backups[0])
+	assert.Nil(err)
+	defer f.Close()
+	gzr, err := gzip.NewReader(f)
+	assert.Nil(err)
+	data, err := io.ReadAll(gzr)
+	assert.Nil(err)
+	assert.Contains("before rotation", string(data))
+}
+
+// TestFileWriterInterval tests the rotation by time.
+func TestFileWriterInterval(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "test.log")
+	fw, err := logger.NewFileWriter(logger.FileWriterConfig{
+		Filename: fn,
+		Interval: 50 * time.Millisecond,
+	})
+	assert.Nil(err)
+	l := logger.New(fw)
+
+	l.Info("first")
+	time.Sleep(60 * time.Millisecond)
+	l.Info("second")
+	assert.Nil(fw.Close())
+
+	backups, err := filepath.Glob(fn + ".*")
+	assert.Nil(err)
+	assert.Length(backups, 1)
+	data, err := os.ReadFile(fn)
+	assert.Nil(err)
+	assert.True(strings.Contains(string(data), "second"))
+	assert.False(strings.Contains(string(data), "first"))
+}
+
+// TestFileWriterConfig tests the validation of the configuration.
+func TestFileWriterConfig(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+
+	_, err := logger.NewFileWriter(logger.FileWriterConfig{})
+	assert.ErrorContains(err, "missing log file name")
+	_, err = logger.NewFileWriter(logger.FileWriterConfig{
+		Filename: filepath.Join(t.TempDir(), "test.log"),
+		MaxSize:  -1,
+	})
+	assert.ErrorContains(err, "invalid negative rotation setting")
+}
+
+// EOF
@@ -0,0 +1,198 @@
+// Tideland Go Trace - Logger - Flight Recorder
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger // import "tideland.dev/go/trace/logger"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"bytes"
+	"container/list"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//--------------------
+// FLIGHT RECORDER
+//--------------------
+
+// Defaults of the flight recorder configuration.
+const (
+	defaultRecorderEntries = 100
+	defaultRecorderKeys    = 1000
+)
+
+// FlightRecorderConfig contains the configuration of a flight recorder.
+type FlightRecorderConfig struct {
+	// Entries is the number of entries kept per goroutine or context,
+	// by default 100.
+	Entries int
+
+	// Trigger is the level of entries writing the recorded ones,
+	// by default LevelError.
+	Trigger LogLevel
+
+	// MaxAge limits the age of the written recorded entries. By
+	// default all are written.
+	MaxAge time.Duration
+
+	// MaxKeys limits the number of goroutines and contexts entries
+	// are kept for, by default 1000. The least recently used are
+	// dropped first.
+	MaxKeys int
+}
+
+// flightRecorder keeps the entries below the level of a logger per
+// goroutine or context.
+type flightRecorder struct {
+	mu         sync.Mutex
+	cfg        FlightRecorderConfig
+	recordings map[string]*list.Element
+	lru        *list.List
+}
+
+// recording contains the kept entries of one goroutine or context.
+type recording struct {
+	key     string
+	entries []Entry
+}
+
+// newFlightRecorder creates a flight recorder with valid configuration.
+func newFlightRecorder(cfg FlightRecorderConfig) *flightRecorder {
+	if cfg.Entries <= 0 {
+		cfg.Entries = defaultRecorderEntries
+	}
+	if cfg.Trigger <= LevelDebug {
+		cfg.Trigger = LevelError
+	}
+	if cfg.MaxKeys <= 0 {
+		cfg.MaxKeys = defaultRecorderKeys
+	}
+	return &flightRecorder{
+		cfg:        cfg,
+		recordings: make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// record keeps the entry for its goroutine or context.
+func (fr *flightRecorder) record(entry Entry) {
+	key := recorderKey(entry)
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	elem, ok := fr.recordings[key]
+	if !ok {
+		elem = fr.lru.PushFront(&recording{key: key})
+		fr.recordings[key] = elem
+		if fr.lru.Len() > fr.cfg.MaxKeys {
+			oldest := fr.lru.Back()
+			fr.lru.Remove(oldest)
+			delete(fr.recordings, oldest.Value.(*recording).key)
+		}
+	} else {
+		fr.lru.MoveToFront(elem)
+	}
+	r := elem.Value.(*recording)
+	if len(r.entries) == fr.cfg.Entries {
+		copy(r.entries, r.entries[1:])
+		r.entries = r.entries[:len(r.entries)-1]
+	}
+	r.entries = append(r.entries, entry)
+}
+
+// take returns and removes the kept entries for the goroutine or context
+// of the entry if it triggers the writing.
+func (fr *flightRecorder) take(entry Entry) []Entry {
+	if entry.Level < fr.cfg.Trigger {
+		return nil
+	}
+	key := recorderKey(entry)
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	elem, ok := fr.recordings[key]
+	if !ok {
+		return nil
+	}
+	fr.lru.Remove(elem)
+	delete(fr.recordings, key)
+	entries := elem.Value.(*recording).entries
+	if fr.cfg.MaxAge > 0 {
+		oldest := entry.Time.Add(-fr.cfg.MaxAge)
+		for len(entries) > 0 && entries[0].Time.Before(oldest) {
+			entries = entries[1:]
+		}
+	}
+	return entries
+}
+
+// recorderKey returns the trace or request ID of the entry if set,
+// otherwise the ID of the current goroutine.
+func recorderKey(entry Entry) string {
+	var requestID string
+	for _, f := range entry.Fields {
+		switch f.Key {
+		case TraceIDKey:
+			return "trace:" + quoteValue(f.Value)
+		case RequestIDKey:
+			if requestID == "" {
+				requestID = "request:" + quoteValue(f.Value)
+			}
+		}
+	}
+	if requestID != "" {
+		return requestID
+	}
+	return "goroutine:" + strconv.FormatUint(goroutineID(), 10)
+}
+
+// goroutineID retrieves the ID of the current goroutine from the
+// header of its stack trace.
+func goroutineID() uint64 {
+	var buf [64]byte
+	stack := buf[:runtime.Stack(buf[:], false)]
+	stack = bytes.TrimPrefix(stack, []byte("goroutine "))
+	if i := bytes.IndexByte(stack, ' '); i > 0 {
+		stack = stack[:i]
+	}
+	id, _ := strconv.ParseUint(string(stack), 10, 64)
+	return id
+}
+
+// SetFlightRecorder lets the logger keep the entries below its level
+// per goroutine or context. When an entry at the trigger level or above
+// is logged in the same goroutine or context, the kept entries are
+// written before it. Contexts are identified by the trace or request ID
+// of the entries.
+func (l *Logger) SetFlightRecorder(cfg FlightRecorderConfig) {
+	l.backend.mu.Lock()
+	defer l.backend.mu.Unlock()
+	l.backend.recorder = newFlightRecorder(cfg)
+}
+
+// UnsetFlightRecorder stops the flight recorder of the logger and
+// drops the kept entries.
+func (l *Logger) UnsetFlightRecorder() {
+	l.backend.mu.Lock()
+	defer l.backend.mu.Unlock()
+	l.backend.recorder = nil
+}
+
+// SetFlightRecorder sets the flight recorder of the default logger.
+func SetFlightRecorder(cfg FlightRecorderConfig) {
+	std.SetFlightRecorder(cfg)
+}
+
+// UnsetFlightRecorder stops the flight recorder of the default logger.
+func UnsetFlightRecorder() {
+	std.UnsetFlightRecorder()
+}
+
+// EOF
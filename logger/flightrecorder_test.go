@@ -0,0 +1,112 @@
+// Tideland Go Trace - Logger - Unit Tests
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/trace/logger"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestFlightRecorder tests writing the recorded entries of a goroutine.
+func TestFlightRecorder(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	cw := logger.NewCaptureWriter()
+	l := logger.New(cw)
+	l.SetFlightRecorder(logger.FlightRecorderConfig{Entries: 3})
+
+	for i := 1; i <= 5; i++ {
+		l.Debug("step", "n", i)
+	}
+	l.Info("info")
+	assert.Equal(cw.Len(), 1)
+	l.Warning("warning")
+	assert.Equal(cw.Len(), 2)
+	l.Error("failed")
+	assert.Equal(messages(cw.Entries()), []string{"info", "warning", "step", "step", "step", "failed"})
+	n, _ := cw.Entries()[2].Field("n")
+	assert.Equal(n, 3)
+
+	// Recorded entries are written only once.
+	l.Errorf("failed again")
+	assert.Equal(cw.Len(), 7)
+
+	// Entries of other goroutines are not written.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		l.Debugf("other")
+	}()
+	wg.Wait()
+	l.Debugf("own")
+	l.Critical("broken")
+	es := cw.Entries()
+	assert.Length(es, 9)
+	assert.Contains("own", es[7].Message)
+
+	l.UnsetFlightRecorder()
+	l.Debug("lost")
+	l.Error("failed")
+	assert.Equal(cw.Len(), 10)
+}
+
+// TestFlightRecorderContext tests writing the recorded entries of a context.
+func TestFlightRecorderContext(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	cw := logger.NewCaptureWriter()
+	l := logger.New(cw)
+	l.SetFlightRecorder(logger.FlightRecorderConfig{Trigger: logger.LevelCritical})
+	ctx := logger.WithRequestID(context.Background(), "r-1")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.DebugContext(ctx, "working")
+		}()
+	}
+	wg.Wait()
+	l.DebugContext(logger.WithRequestID(context.Background(), "r-2"), "other request")
+	l.ErrorContext(ctx, "no trigger")
+	assert.Equal(cw.Len(), 1)
+	l.CriticalContext(ctx, "broken")
+	es := cw.Entries()
+	assert.Equal(messages(es), []string{"no trigger", "working", "working", "working", "broken"})
+	id, _ := es[1].Field(logger.RequestIDKey)
+	assert.Equal(id, "r-1")
+}
+
+// TestFlightRecorderMaxAge tests dropping old recorded entries.
+func TestFlightRecorderMaxAge(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	cw := logger.NewCaptureWriter()
+	l := logger.New(cw)
+	l.SetFlightRecorder(logger.FlightRecorderConfig{MaxAge: 50 * time.Millisecond})
+
+	l.Debug("old")
+	time.Sleep(100 * time.Millisecond)
+	l.Debug("new")
+	l.Error("failed")
+	assert.Equal(messages(cw.Entries()), []string{"new", "failed"})
+}
+
+// EOF
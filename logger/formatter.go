@@ -0,0 +1,276 @@
+// Tideland Go Trace - Logger - Formatters
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger // import "tideland.dev/go/trace/logger"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"tideland.dev/go/trace/failure"
+)
+
+//--------------------
+// FORMATTER
+//--------------------
+
+// Formatter renders entries independent of the destination they
+// are written to.
+type Formatter interface {
+	// Format renders the entry including a terminating newline.
+	Format(entry Entry) ([]byte, error)
+}
+
+// FormatterFunc allows to use a function as Formatter.
+type FormatterFunc func(entry Entry) ([]byte, error)
+
+// Format implements Formatter.
+func (ff FormatterFunc) Format(entry Entry) ([]byte, error) {
+	return ff(entry)
+}
+
+//--------------------
+// TEXT FORMATTER
+//--------------------
+
+// NewTextFormatter creates a formatter rendering the timestamp in the
+// passed format, the level, and the text of the entry like the standard
+// writer. An empty time format means the one of the standard writer.
+func NewTextFormatter(timeFormat string) Formatter {
+	if timeFormat == "" {
+		timeFormat = defaultTimeFormat
+	}
+	return FormatterFunc(func(entry Entry) ([]byte, error) {
+		line := fmt.Sprintf("%s [%s] %s\n", entry.Time.Format(timeFormat), levelToText(entry.Level), entry.Text())
+		return []byte(line), nil
+	})
+}
+
+// templateFuncs are the additional functions of templates.
+var templateFuncs = template.FuncMap{
+	"lower": func(value interface{}) string {
+		return strings.ToLower(valueText(value))
+	},
+	"upper": func(value interface{}) string {
+		return strings.ToUpper(valueText(value))
+	},
+	"short": ShortLocation,
+	"quote": quoteValue,
+	"field": func(entry Entry, key string) interface{} {
+		value, _ := entry.Field(key)
+		return value
+	},
+}
+
+// NewTemplateFormatter creates a formatter executing a text template
+// with the entry as data, e.g. to match legacy log formats exactly.
+// Beside the fields and methods of Entry the functions "lower", "upper",
+// "short" for short locations, "quote" for quoted values if needed, and
+// "field" for a single field value can be used. A missing newline at
+// the end is added.
+func NewTemplateFormatter(text string) (Formatter, error) {
+	tmpl, err := template.New("entry").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, failure.Annotate(err, "invalid format template")
+	}
+	return FormatterFunc(func(entry Entry) ([]byte, error) {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, entry); err != nil {
+			return nil, failure.Annotate(err, "cannot format entry")
+		}
+		if !bytes.HasSuffix(buf.Bytes(), []byte{'\n'}) {
+			buf.WriteByte('\n')
+		}
+		return buf.Bytes(), nil
+	}), nil
+}
+
+//--------------------
+// JSON FORMATTERS
+//--------------------
+
+// NewJSONFormatter creates a formatter rendering one JSON object per
+// entry like the JSON writer.
+func NewJSONFormatter() Formatter {
+	return FormatterFunc(func(entry Entry) ([]byte, error) {
+		return append(marshalEntry(entry), '\n'), nil
+	})
+}
+
+// NewGELFFormatter creates a formatter rendering the entries according
+// to the Graylog Extended Log Format 1.1. The location and the fields
+// are passed as additional fields prefixed with an underscore.
+func NewGELFFormatter(host string) Formatter {
+	return FormatterFunc(func(entry Entry) ([]byte, error) {
+		var buf bytes.Buffer
+		buf.WriteString(`{"version":"1.1","host":`)
+		writeJSONValue(&buf, host)
+		buf.WriteString(`,"short_message":`)
+		writeJSONValue(&buf, entry.Message)
+		buf.WriteString(`,"timestamp":`)
+		buf.WriteString(strconv.FormatFloat(float64(entry.Time.UnixNano())/float64(time.Second), 'f', 3, 64))
+		buf.WriteString(`,"level":`)
+		buf.WriteString(strconv.Itoa(severity(entry.Level)))
+		if entry.Location.ID != "" {
+			buf.WriteString(`,"_package":`)
+			writeJSONValue(&buf, entry.Location.Package)
+			buf.WriteString(`,"_file":`)
+			writeJSONValue(&buf, entry.Location.File)
+			buf.WriteString(`,"_func":`)
+			writeJSONValue(&buf, entry.Location.Func)
+			buf.WriteString(`,"_line":`)
+			writeJSONValue(&buf, entry.Location.Line)
+		}
+		for _, f := range entry.Fields {
+			buf.WriteString(`,`)
+			writeJSONValue(&buf, "_"+gelfFieldName(f.Key))
+			buf.WriteByte(':')
+			writeJSONValue(&buf, f.Value)
+		}
+		buf.WriteString("}\n")
+		return buf.Bytes(), nil
+	})
+}
+
+// gelfFieldName replaces the characters not allowed in GELF field
+// names. The reserved name "id" gets a suffix.
+func gelfFieldName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r == '_', r == '.', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+	if name == "id" {
+		return "id_"
+	}
+	return name
+}
+
+//--------------------
+// CEF FORMATTER
+//--------------------
+
+// cefSeverity maps the log levels to CEF severities.
+var cefSeverity = map[LogLevel]int{
+	LevelDebug:    1,
+	LevelInfo:     3,
+	LevelWarning:  5,
+	LevelError:    7,
+	LevelCritical: 9,
+	LevelFatal:    10,
+}
+
+// NewCEFFormatter creates a formatter rendering the entries in the
+// ArcSight Common Event Format. The level is used as signature ID,
+// the message as name. The extension contains the time as "rt", the
+// location as "cs1", and the fields.
+func NewCEFFormatter(vendor, product, version string) Formatter {
+	header := "CEF:0|" + cefHeader(vendor) + "|" + cefHeader(product) + "|" + cefHeader(version) + "|"
+	return FormatterFunc(func(entry Entry) ([]byte, error) {
+		var buf bytes.Buffer
+		buf.WriteString(header)
+		buf.WriteString(cefHeader(strings.ToLower(levelToText(entry.Level))))
+		buf.WriteByte('|')
+		buf.WriteString(cefHeader(entry.Message))
+		buf.WriteByte('|')
+		buf.WriteString(strconv.Itoa(cefSeverity[entry.Level]))
+		buf.WriteString("|rt=")
+		buf.WriteString(strconv.FormatInt(entry.Time.UnixNano()/int64(time.Millisecond), 10))
+		if entry.Location.ID != "" {
+			buf.WriteString(" cs1Label=location cs1=")
+			buf.WriteString(cefValue(entry.Location.ID))
+		}
+		for _, f := range entry.Fields {
+			buf.WriteByte(' ')
+			buf.WriteString(cefKey(f.Key))
+			buf.WriteByte('=')
+			buf.WriteString(cefValue(valueText(f.Value)))
+		}
+		buf.WriteByte('\n')
+		return buf.Bytes(), nil
+	})
+}
+
+// cefHeader escapes the values of the CEF header.
+func cefHeader(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ").Replace(s)
+	return s
+}
+
+// cefValue escapes the values of the CEF extension.
+func cefValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`).Replace(s)
+}
+
+// cefKey removes the characters not allowed in CEF extension keys.
+func cefKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, key)
+}
+
+//--------------------
+// FORMAT WRITER
+//--------------------
+
+// formatWriter writes the formatted entries to any destination.
+type formatWriter struct {
+	mu        sync.Mutex
+	out       io.Writer
+	formatter Formatter
+}
+
+// NewFormatWriter creates a writer rendering the entries with the
+// formatter and writing them to the passed output, e.g. a file or
+// a network connection.
+func NewFormatWriter(out io.Writer, formatter Formatter) Writer {
+	return &formatWriter{
+		out:       out,
+		formatter: formatter,
+	}
+}
+
+// Write implements Writer.
+func (w *formatWriter) Write(level LogLevel, msg string) error {
+	return w.WriteEntry(Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: msg,
+	})
+}
+
+// WriteEntry implements EntryWriter.
+func (w *formatWriter) WriteEntry(entry Entry) error {
+	data, err := w.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = w.out.Write(data)
+	return err
+}
+
+// EOF
@@ -0,0 +1,144 @@
+// Tideland Go Trace - Logger - Unit Tests
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/trace/location"
+	"tideland.dev/go/trace/logger"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestTextFormatter tests the text formatter with a format writer.
+func TestTextFormatter(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	buf := &bytes.Buffer{}
+	w := logger.NewFormatWriter(buf, logger.NewTextFormatter(time.RFC3339))
+
+	assert.Nil(w.(logger.EntryWriter).WriteEntry(formatterEntry()))
+	assert.Equal(buf.String(), "2020-05-01T12:30:45Z [WARNING] tideland.dev/go/trace/logger:disk.go:check:42 disk full path=/var quota=\"90 %\"\n")
+}
+
+// TestTemplateFormatter tests formatting entries with a template.
+func TestTemplateFormatter(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+
+	f, err := logger.NewTemplateFormatter(`{{.Time.Format "Jan _2 15:04:05"}} {{printf "%-8s" .Level}} {{short .Location}} ` +
+		`{{upper .Message}} path={{field . "path"}} missing={{field . "missing"}}`)
+	assert.Nil(err)
+	data, err := f.Format(formatterEntry())
+	assert.Nil(err)
+	assert.Equal(string(data), "May  1 12:30:45 WARNING  logger/disk.go:42 DISK FULL path=/var missing=<no value>\n")
+
+	f, err = logger.NewTemplateFormatter("{{.Level | lower}} {{quote .Message}}\n")
+	assert.Nil(err)
+	data, err = f.Format(formatterEntry())
+	assert.Nil(err)
+	assert.Equal(string(data), "warning \"disk full\"\n")
+
+	_, err = logger.NewTemplateFormatter("{{.Level")
+	assert.ErrorContains(err, "invalid format template")
+	f, err = logger.NewTemplateFormatter("{{.Unknown}}")
+	assert.Nil(err)
+	_, err = f.Format(formatterEntry())
+	assert.ErrorContains(err, "cannot format entry")
+}
+
+// TestGELFFormatter tests formatting entries as GELF.
+func TestGELFFormatter(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	entry := formatterEntry()
+	entry.Fields = append(entry.Fields, logger.F("id", 7), logger.F("user name", "foo"))
+
+	data, err := logger.NewGELFFormatter("web-1").Format(entry)
+	assert.Nil(err)
+	assert.True(bytes.HasSuffix(data, []byte("}\n")))
+
+	var gelf map[string]interface{}
+	assert.Nil(json.Unmarshal(data, &gelf))
+	assert.Equal(gelf["version"], "1.1")
+	assert.Equal(gelf["host"], "web-1")
+	assert.Equal(gelf["short_message"], "disk full")
+	assert.Equal(gelf["timestamp"], 1588336245.0)
+	assert.Equal(gelf["level"], 4.0)
+	assert.Equal(gelf["_file"], "disk.go")
+	assert.Equal(gelf["_line"], 42.0)
+	assert.Equal(gelf["_path"], "/var")
+	assert.Equal(gelf["_id_"], 7.0)
+	assert.Equal(gelf["_user_name"], "foo")
+}
+
+// TestCEFFormatter tests formatting entries as CEF.
+func TestCEFFormatter(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	entry := formatterEntry()
+	entry.Message = "disk|full"
+	entry.Fields = append(entry.Fields, logger.F("expr", "a=b"))
+
+	data, err := logger.NewCEFFormatter("Tideland", "Go Trace", "1.0").Format(entry)
+	assert.Nil(err)
+	assert.Equal(string(data), `CEF:0|Tideland|Go Trace|1.0|warning|disk\|full|5|rt=1588336245000 `+
+		`cs1Label=location cs1=tideland.dev/go/trace/logger:disk.go:check:42 path=/var quota=90 % expr=a\=b`+"\n")
+}
+
+// TestFileWriterFormatter tests a file writer with an own formatter.
+func TestFileWriterFormatter(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	filename := filepath.Join(t.TempDir(), "app.log")
+	fw, err := logger.NewFileWriter(logger.FileWriterConfig{
+		Filename:  filename,
+		Formatter: logger.NewJSONFormatter(),
+	})
+	assert.Nil(err)
+	l := logger.New(fw)
+
+	l.Info("started", "port", 8080)
+	assert.Nil(fw.Close())
+
+	lines := readLines(assert, filename)
+	assert.Length(lines, 1)
+	assert.True(strings.HasPrefix(lines[0], `{"time":`))
+	assert.Contains(`"fields":{"port":8080}`, lines[0])
+}
+
+//--------------------
+// HELPERS
+//--------------------
+
+// formatterEntry returns a fixed entry for the formatter tests.
+func formatterEntry() logger.Entry {
+	return logger.Entry{
+		Time:  time.Date(2020, time.May, 1, 12, 30, 45, 0, time.UTC),
+		Level: logger.LevelWarning,
+		Location: location.Location{
+			ID:      "tideland.dev/go/trace/logger:disk.go:check:42",
+			Package: "tideland.dev/go/trace/logger",
+			File:    "disk.go",
+			Func:    "check",
+			Line:    42,
+		},
+		Message: "disk full",
+		Fields:  logger.Fields{logger.F("path", "/var"), logger.F("quota", "90 %")},
+	}
+}
+
+// EOF
@@ -0,0 +1,308 @@
+// Tideland Go Trace - Logger - Level Handler
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger // import "tideland.dev/go/trace/logger"
+
+//--------------------
+// IMPORT
+//--------------------
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"tideland.dev/go/trace/failure"
+)
+
+//--------------------
+// LEVEL STATE
+//--------------------
+
+// LevelState contains the current level settings of a logger.
+type LevelState struct {
+	Level     string            `json:"level"`
+	Writers   map[string]string `json:"writers,omitempty"`
+	Overrides []LevelOverride   `json:"overrides,omitempty"`
+	RevertAt  *time.Time        `json:"revert_at,omitempty"`
+}
+
+// LevelChange describes a change of the level settings. Levels are
+// passed by name, e.g. "debug" or "warning". Overrides are set like
+// with SetLevelOverride(), those in Unset are removed by their
+// selectors. A duration like "5m" reverts the change after it.
+type LevelChange struct {
+	Level     string            `json:"level,omitempty"`
+	Writers   map[string]string `json:"writers,omitempty"`
+	Overrides []LevelOverride   `json:"overrides,omitempty"`
+	Unset     []LevelOverride   `json:"unset,omitempty"`
+	Duration  string            `json:"duration,omitempty"`
+}
+
+// targetLeveler is implemented by writers with levels per target
+// like the multi writer.
+type targetLeveler interface {
+	Levels() map[string]LogLevel
+	SetTargetLevel(name string, level LogLevel) (LogLevel, error)
+}
+
+// isTargetLeveler checks if the writer has levels per target.
+func isTargetLeveler(w Writer) bool {
+	_, ok := w.(targetLeveler)
+	return ok
+}
+
+//--------------------
+// HANDLER
+//--------------------
+
+// Handler implements the http.Handler for reading and changing
+// the levels of a logger at runtime.
+type Handler struct {
+	mu       sync.Mutex
+	logger   *Logger
+	timer    *time.Timer
+	timerGen int
+	revertAt time.Time
+	saved    *savedLevels
+}
+
+// savedLevels contains the levels to restore after a temporary change.
+type savedLevels struct {
+	level     *LogLevel
+	writers   map[string]LogLevel
+	overrides []savedOverride
+}
+
+// savedOverride contains an override to restore or to remove if
+// it did not exist before.
+type savedOverride struct {
+	override LevelOverride
+	existed  bool
+}
+
+// saveOverride saves the current override with the same selectors
+// if none is saved yet.
+func (sl *savedLevels) saveOverride(current []LevelOverride, o LevelOverride) {
+	for _, so := range sl.overrides {
+		if so.override.sameSelectors(o) {
+			return
+		}
+	}
+	so := savedOverride{
+		override: o,
+	}
+	for _, co := range current {
+		if co.sameSelectors(o) {
+			so.override = co
+			so.existed = true
+			break
+		}
+	}
+	sl.overrides = append(sl.overrides, so)
+}
+
+// NewHandler returns an instance of a web handler for the passed
+// logger. If it is nil the default logger is used.
+func NewHandler(l *Logger) *Handler {
+	if l == nil {
+		l = std
+	}
+	return &Handler{
+		logger: l,
+	}
+}
+
+// ServeHTTP implements the handling function.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		// Retrieve the current levels.
+		h.reply(w)
+		return
+	case http.MethodPut, http.MethodPost:
+		// Change the levels.
+		var change LevelChange
+		err := json.NewDecoder(r.Body).Decode(&change)
+		if err != nil {
+			http.Error(w, "invalid level change: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		status, err := h.change(change)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		h.reply(w)
+		return
+	}
+	http.Error(w, "only GET, PUT, and POST allowed", http.StatusMethodNotAllowed)
+}
+
+// reply writes the current level state.
+func (h *Handler) reply(w http.ResponseWriter) {
+	enc := json.NewEncoder(w)
+	w.Header().Set("Content-Type", "application/json")
+	err := enc.Encode(h.state())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// state returns the current level state.
+func (h *Handler) state() LevelState {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	state := LevelState{
+		Level:     levelToText(h.logger.Level()),
+		Overrides: h.logger.LevelOverrides(),
+	}
+	if tl, ok := h.targetLeveler(); ok {
+		state.Writers = make(map[string]string)
+		for name, level := range tl.Levels() {
+			state.Writers[name] = levelToText(level)
+		}
+	}
+	if h.timer != nil {
+		revertAt := h.revertAt
+		state.RevertAt = &revertAt
+	}
+	return state
+}
+
+// change validates and performs the level change. In case of an
+// error the HTTP status code is returned too.
+func (h *Handler) change(change LevelChange) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// Validate all values first.
+	var duration time.Duration
+	var err error
+	if change.Duration != "" {
+		duration, err = time.ParseDuration(change.Duration)
+		if err != nil || duration <= 0 {
+			return http.StatusBadRequest, failure.New("invalid duration %q", change.Duration)
+		}
+	}
+	var level LogLevel
+	if change.Level != "" {
+		level, err = ParseLevel(change.Level)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+	}
+	writers := make(map[string]LogLevel)
+	tl, ok := h.targetLeveler()
+	if len(change.Writers) > 0 && !ok {
+		return http.StatusBadRequest, failure.New("cannot reach writer targets: logger writer has no levels per target")
+	}
+	for name, text := range change.Writers {
+		if _, ok := tl.Levels()[name]; !ok {
+			return http.StatusNotFound, failure.New("writer target %q not found", name)
+		}
+		writers[name], err = ParseLevel(text)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+	}
+	for _, o := range append(change.Overrides, change.Unset...) {
+		if o.Package == "" && o.File == "" && o.Func == "" {
+			return http.StatusBadRequest, failure.New("invalid level override without selectors")
+		}
+	}
+	// Perform the change and save the current values if temporary.
+	if h.timer != nil {
+		h.timer.Stop()
+		h.timer = nil
+	}
+	if duration == 0 {
+		h.saved = nil
+	} else if h.saved == nil {
+		h.saved = &savedLevels{
+			writers: make(map[string]LogLevel),
+		}
+	}
+	current := h.logger.LevelOverrides()
+	for _, o := range change.Overrides {
+		if h.saved != nil {
+			h.saved.saveOverride(current, o)
+		}
+		h.logger.SetLevelOverride(o)
+	}
+	for _, o := range change.Unset {
+		if h.saved != nil {
+			h.saved.saveOverride(current, o)
+		}
+		h.logger.UnsetLevelOverride(o)
+	}
+	if change.Level != "" {
+		current := h.logger.SetLevel(level)
+		if h.saved != nil && h.saved.level == nil {
+			h.saved.level = &current
+		}
+	}
+	for name, level := range writers {
+		current, _ := tl.SetTargetLevel(name, level)
+		if h.saved != nil {
+			if _, ok := h.saved.writers[name]; !ok {
+				h.saved.writers[name] = current
+			}
+		}
+	}
+	if duration > 0 {
+		h.revertAt = time.Now().Add(duration)
+		h.timerGen++
+		gen := h.timerGen
+		h.timer = time.AfterFunc(duration, func() {
+			h.revert(gen)
+		})
+	}
+	return http.StatusOK, nil
+}
+
+// revert restores the levels saved before a temporary change if
+// the timer has not been replaced in the meantime.
+func (h *Handler) revert(gen int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.saved == nil || gen != h.timerGen {
+		return
+	}
+	if h.saved.level != nil {
+		h.logger.SetLevel(*h.saved.level)
+	}
+	if tl, ok := h.targetLeveler(); ok {
+		for name, level := range h.saved.writers {
+			_, _ = tl.SetTargetLevel(name, level)
+		}
+	}
+	for _, so := range h.saved.overrides {
+		if so.existed {
+			h.logger.SetLevelOverride(so.override)
+		} else {
+			h.logger.UnsetLevelOverride(so.override)
+		}
+	}
+	h.saved = nil
+	h.timer = nil
+}
+
+// targetLeveler returns the writer of the logger or the first one
+// wrapped by it having levels per target.
+func (h *Handler) targetLeveler() (targetLeveler, bool) {
+	h.logger.backend.mu.RLock()
+	out := h.logger.backend.out
+	h.logger.backend.mu.RUnlock()
+	w, ok := findWriter(out, isTargetLeveler)
+	if !ok {
+		return nil, false
+	}
+	return w.(targetLeveler), true
+}
+
+// EOF
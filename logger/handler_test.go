@@ -0,0 +1,232 @@
+// Tideland Go Trace - Logger - Unit Tests
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/audit/environments"
+	"tideland.dev/go/trace/logger"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestWebLevels tests retrieving and changing the levels via web handler.
+func TestWebLevels(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	wa := environments.NewWebAsserter(assert)
+	defer wa.Close()
+
+	mw := logger.NewMultiWriter(
+		logger.Target{Name: "file", Writer: logger.NewTestWriter(), Level: logger.LevelInfo},
+		logger.Target{Name: "syslog", Writer: logger.NewTestWriter(), Level: logger.LevelWarning},
+	)
+	l := logger.New(mw)
+
+	wa.Handle("/logger/", logger.NewHandler(l))
+
+	wreq := wa.CreateRequest(http.MethodGet, "/logger/")
+	wresp := wreq.Do()
+	wresp.AssertStatusCodeEquals(http.StatusOK)
+	wresp.Header().AssertKeyContainsValue("Content-Type", environments.ContentTypeJSON)
+	wresp.AssertBodyContains(`"level":"INFO"`)
+	wresp.AssertBodyContains(`"syslog":"WARNING"`)
+
+	wreq = wa.CreateRequest(http.MethodPut, "/logger/")
+	wreq.SetContentType(environments.ContentTypeJSON)
+	wreq.AssertMarshalBody(logger.LevelChange{
+		Level:   "debug",
+		Writers: map[string]string{"syslog": "Error"},
+	})
+	wresp = wreq.Do()
+	wresp.AssertStatusCodeEquals(http.StatusOK)
+	wresp.AssertBodyContains(`"level":"DEBUG"`)
+	wresp.AssertBodyContains(`"syslog":"ERROR"`)
+	assert.Equal(l.Level(), logger.LevelDebug)
+	assert.Equal(mw.Levels()["syslog"], logger.LevelError)
+}
+
+// TestWebTemporaryLevels tests the reverting of temporary changes.
+func TestWebTemporaryLevels(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	wa := environments.NewWebAsserter(assert)
+	defer wa.Close()
+
+	l := logger.New(logger.NewTestWriter())
+	l.SetLevel(logger.LevelWarning)
+
+	wa.Handle("/logger/", logger.NewHandler(l))
+
+	wreq := wa.CreateRequest(http.MethodPost, "/logger/")
+	wreq.SetContentType(environments.ContentTypeJSON)
+	wreq.AssertMarshalBody(logger.LevelChange{
+		Level:    "debug",
+		Duration: "50ms",
+	})
+	wresp := wreq.Do()
+	wresp.AssertStatusCodeEquals(http.StatusOK)
+	wresp.AssertBodyContains(`"revert_at"`)
+	assert.Equal(l.Level(), logger.LevelDebug)
+
+	assert.Wait(waitForLevel(l, logger.LevelWarning), true, time.Second)
+}
+
+// TestWebOverrides tests setting, removing, and reverting level
+// overrides via web handler.
+func TestWebOverrides(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	wa := environments.NewWebAsserter(assert)
+	defer wa.Close()
+
+	l := logger.New(logger.NewTestWriter())
+	l.SetPackageLevel("example.com/db", logger.LevelError)
+
+	wa.Handle("/logger/", logger.NewHandler(l))
+
+	wreq := wa.CreateRequest(http.MethodPut, "/logger/")
+	wreq.SetContentType(environments.ContentTypeJSON)
+	wreq.AssertMarshalBody(logger.LevelChange{
+		Overrides: []logger.LevelOverride{
+			{Package: "example.com/db", Level: logger.LevelDebug},
+			{Package: "example.com/web", File: "server.go", Level: logger.LevelWarning},
+		},
+		Duration: "50ms",
+	})
+	wresp := wreq.Do()
+	wresp.AssertStatusCodeEquals(http.StatusOK)
+	wresp.AssertBodyContains(`{"package":"example.com/db","level":"DEBUG"}`)
+	wresp.AssertBodyContains(`{"package":"example.com/web","file":"server.go","level":"WARNING"}`)
+	assert.Length(l.LevelOverrides(), 2)
+
+	assert.Wait(waitForOverrides(l, 1), true, time.Second)
+	ovs := l.LevelOverrides()
+	assert.Equal(ovs[0].Package, "example.com/db")
+	assert.Equal(ovs[0].Level, logger.LevelError)
+
+	wreq = wa.CreateRequest(http.MethodPut, "/logger/")
+	wreq.SetContentType(environments.ContentTypeJSON)
+	wreq.AssertMarshalBody(logger.LevelChange{
+		Unset: []logger.LevelOverride{{Package: "example.com/db"}},
+	})
+	wresp = wreq.Do()
+	wresp.AssertStatusCodeEquals(http.StatusOK)
+	assert.Length(l.LevelOverrides(), 0)
+}
+
+// TestWebWrappedWriter tests changing the levels of a multi writer
+// wrapped by other writers.
+func TestWebWrappedWriter(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	wa := environments.NewWebAsserter(assert)
+	defer wa.Close()
+
+	mw := logger.NewMultiWriter(
+		logger.Target{Name: "file", Writer: logger.NewTestWriter(), Level: logger.LevelInfo},
+	)
+	sw := logger.NewSamplingWriter(logger.NewRedactingWriter(mw, logger.DefaultRedactionConfig()), logger.SamplingConfig{
+		First:    10,
+		Interval: time.Hour,
+	})
+	defer sw.Close()
+	l := logger.New(sw)
+
+	wa.Handle("/logger/", logger.NewHandler(l))
+
+	wreq := wa.CreateRequest(http.MethodPut, "/logger/")
+	wreq.SetContentType(environments.ContentTypeJSON)
+	wreq.AssertMarshalBody(logger.LevelChange{
+		Writers: map[string]string{"file": "error"},
+	})
+	wresp := wreq.Do()
+	wresp.AssertStatusCodeEquals(http.StatusOK)
+	wresp.AssertBodyContains(`"file":"ERROR"`)
+	assert.Equal(mw.Levels()["file"], logger.LevelError)
+
+	wreq = wa.CreateRequest(http.MethodPut, "/logger/")
+	wreq.SetContentType(environments.ContentTypeJSON)
+	wreq.AssertMarshalBody(logger.LevelChange{
+		Writers: map[string]string{"syslog": "error"},
+	})
+	wresp = wreq.Do()
+	wresp.AssertStatusCodeEquals(http.StatusNotFound)
+	wresp.AssertBodyContains(`writer target "syslog" not found`)
+}
+
+// TestWebInvalid tests invalid requests.
+func TestWebInvalid(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	wa := environments.NewWebAsserter(assert)
+	defer wa.Close()
+
+	wa.Handle("/logger/", logger.NewHandler(logger.New(logger.NewTestWriter())))
+
+	tests := []struct {
+		body string
+		msg  string
+	}{
+		{`{"level":"loud"}`, `invalid log level "loud"`},
+		{`{"writers":{"file":"debug"}}`, "logger writer has no levels per target"},
+		{`{"level":"info","duration":"soon"}`, `invalid duration "soon"`},
+		{`{"overrides":[{"level":"debug"}]}`, "invalid level override without selectors"},
+		{`{"overrides":[{"package":"foo","level":"loud"}]}`, "invalid level change"},
+		{`level=info`, "invalid level change"},
+	}
+	for _, test := range tests {
+		wreq := wa.CreateRequest(http.MethodPut, "/logger/")
+		wreq.SetContentType(environments.ContentTypeJSON)
+		wreq.AssertRenderTemplate(test.body, nil)
+		wresp := wreq.Do()
+		wresp.AssertStatusCodeEquals(http.StatusBadRequest)
+		wresp.AssertBodyContains(test.msg)
+	}
+
+	wreq := wa.CreateRequest(http.MethodDelete, "/logger/")
+	wresp := wreq.Do()
+	wresp.AssertStatusCodeEquals(http.StatusMethodNotAllowed)
+	wresp.AssertBodyContains("only GET, PUT, and POST allowed")
+}
+
+//--------------------
+// HELPERS
+//--------------------
+
+// waitForLevel signals true when the logger has the wanted level.
+func waitForLevel(l *logger.Logger, level logger.LogLevel) chan interface{} {
+	sigc := make(chan interface{}, 1)
+	go func() {
+		for l.Level() != level {
+			time.Sleep(5 * time.Millisecond)
+		}
+		sigc <- true
+	}()
+	return sigc
+}
+
+// waitForOverrides signals true when the logger has the wanted
+// number of level overrides.
+func waitForOverrides(l *logger.Logger, n int) chan interface{} {
+	sigc := make(chan interface{}, 1)
+	go func() {
+		for len(l.LevelOverrides()) != n {
+			time.Sleep(5 * time.Millisecond)
+		}
+		sigc <- true
+	}()
+	return sigc
+}
+
+// EOF
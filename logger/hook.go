@@ -0,0 +1,174 @@
+// Tideland Go Trace - Logger - Hooks
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger // import "tideland.dev/go/trace/logger"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"strings"
+
+	"tideland.dev/go/trace/monitor"
+)
+
+//--------------------
+// LEVEL MASK
+//--------------------
+
+// LevelMask selects a set of log levels.
+type LevelMask uint
+
+// AllLevels is the mask selecting all log levels.
+const AllLevels LevelMask = 1<<(LevelFatal+1) - 1
+
+// MaskOf returns a mask selecting the passed levels.
+func MaskOf(levels ...LogLevel) LevelMask {
+	var mask LevelMask
+	for _, level := range levels {
+		if level >= LevelDebug && level <= LevelFatal {
+			mask |= 1 << level
+		}
+	}
+	return mask
+}
+
+// MaskFrom returns a mask selecting the passed level and all above.
+func MaskFrom(level LogLevel) LevelMask {
+	if level < LevelDebug {
+		level = LevelDebug
+	}
+	return AllLevels &^ (1<<level - 1)
+}
+
+// Contains checks if the mask selects the passed level.
+func (m LevelMask) Contains(level LogLevel) bool {
+	if level < LevelDebug || level > LevelFatal {
+		return false
+	}
+	return m&(1<<level) != 0
+}
+
+//--------------------
+// HOOKS
+//--------------------
+
+// HookFunc defines a function called for logged entries. Hooks are
+// called after the entry has been written and without holding any
+// lock of the logger. So they may use the logger, but must not log
+// entries selected by their own mask.
+type HookFunc func(entry Entry)
+
+// entryHook is one registered hook.
+type entryHook struct {
+	name string
+	mask LevelMask
+	hook HookFunc
+}
+
+// entryHooks contains the hooks of a logger. It is never changed, only
+// replaced, so it can be used without holding the lock of the backend.
+type entryHooks []entryHook
+
+// with returns new hooks containing the passed one, replacing one
+// with the same name.
+func (hs entryHooks) with(h entryHook) entryHooks {
+	nhs := make(entryHooks, 0, len(hs)+1)
+	for _, ch := range hs {
+		if ch.name != h.name {
+			nhs = append(nhs, ch)
+		}
+	}
+	return append(nhs, h)
+}
+
+// without returns new hooks without the one with the passed name.
+func (hs entryHooks) without(name string) entryHooks {
+	nhs := make(entryHooks, 0, len(hs))
+	for _, ch := range hs {
+		if ch.name != name {
+			nhs = append(nhs, ch)
+		}
+	}
+	return nhs
+}
+
+// call calls all hooks selecting the level of the entry. Panics of
+// hooks are recovered to not disturb the logging.
+func (hs entryHooks) call(entry Entry) {
+	for _, h := range hs {
+		if h.mask.Contains(entry.Level) {
+			func() {
+				defer func() {
+					_ = recover()
+				}()
+				h.hook(entry)
+			}()
+		}
+	}
+}
+
+// AddHook registers a named hook called for all written entries with
+// a level selected by the mask. A hook with an already registered name
+// is replaced.
+func (l *Logger) AddHook(name string, mask LevelMask, hook HookFunc) {
+	l.backend.mu.Lock()
+	defer l.backend.mu.Unlock()
+	l.backend.hooks = l.backend.hooks.with(entryHook{name, mask, hook})
+}
+
+// RemoveHook removes the hook with the passed name.
+func (l *Logger) RemoveHook(name string) {
+	l.backend.mu.Lock()
+	defer l.backend.mu.Unlock()
+	l.backend.hooks = l.backend.hooks.without(name)
+}
+
+// AddHook registers a named hook of the default logger.
+func AddHook(name string, mask LevelMask, hook HookFunc) {
+	std.AddHook(name, mask, hook)
+}
+
+// RemoveHook removes the named hook of the default logger.
+func RemoveHook(name string) {
+	std.RemoveHook(name)
+}
+
+//--------------------
+// STANDARD HOOKS
+//--------------------
+
+// NewChannelHook returns a hook sending the entries to the passed
+// channel, e.g. for alerting. If the channel is full the entry is
+// dropped instead of blocking the logging goroutine.
+func NewChannelHook(entryc chan<- Entry) HookFunc {
+	return func(entry Entry) {
+		select {
+		case entryc <- entry:
+		default:
+		}
+	}
+}
+
+// NewIndicatorHook returns a hook increasing the stay-set indicator
+// of the monitor for each entry. The IDs are the prefix followed by
+// the lowercase level, e.g. "logger.error".
+func NewIndicatorHook(m *monitor.Monitor, prefix string) HookFunc {
+	ssi := m.StaySetIndicator()
+	ids := make(map[LogLevel]string, len(levelText))
+	for level, text := range levelText {
+		ids[level] = prefix + strings.ToLower(text)
+	}
+	return func(entry Entry) {
+		if id, ok := ids[entry.Level]; ok {
+			ssi.Increase(id)
+		}
+	}
+}
+
+// EOF
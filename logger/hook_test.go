@@ -0,0 +1,105 @@
+// Tideland Go Trace - Logger - Unit Tests
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/trace/logger"
+	"tideland.dev/go/trace/monitor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestLevelMask tests the selection of levels by masks.
+func TestLevelMask(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+
+	mask := logger.MaskOf(logger.LevelDebug, logger.LevelError)
+	assert.True(mask.Contains(logger.LevelDebug))
+	assert.False(mask.Contains(logger.LevelInfo))
+	assert.True(mask.Contains(logger.LevelError))
+
+	mask = logger.MaskFrom(logger.LevelError)
+	assert.False(mask.Contains(logger.LevelWarning))
+	assert.True(mask.Contains(logger.LevelError))
+	assert.True(mask.Contains(logger.LevelFatal))
+	assert.False(mask.Contains(logger.LogLevel(42)))
+
+	assert.Equal(logger.MaskFrom(logger.LevelDebug), logger.AllLevels)
+}
+
+// TestHooks tests calling hooks for selected levels.
+func TestHooks(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	tw := logger.NewTestWriter()
+	l := logger.New(tw)
+	alertc := make(chan logger.Entry, 1)
+	var msgs []string
+
+	l.AddHook("alert", logger.MaskFrom(logger.LevelCritical), logger.NewChannelHook(alertc))
+	l.AddHook("collect", logger.MaskOf(logger.LevelInfo, logger.LevelError), func(entry logger.Entry) {
+		msgs = append(msgs, entry.Message)
+		// Using the logger inside of hooks must not deadlock.
+		l.SetLevel(l.Level())
+		l.Warning("hook called")
+	})
+	l.AddHook("panic", logger.AllLevels, func(entry logger.Entry) {
+		panic("ouch")
+	})
+
+	l.Info("one", "n", 1)
+	l.Error("two")
+	l.Critical("three")
+	l.Critical("four")
+	assert.Equal(msgs, []string{"one", "two"})
+	assert.Equal(tw.Len(), 6)
+	alert := <-alertc
+	assert.Equal(alert.Message, "three")
+	assert.Length(alertc, 0)
+
+	// Child loggers share the hooks.
+	l.Named("child").Info("five")
+	assert.Equal(msgs, []string{"one", "two", "five"})
+
+	l.RemoveHook("collect")
+	l.Info("six")
+	assert.Equal(msgs, []string{"one", "two", "five"})
+}
+
+// TestIndicatorHook tests counting entries with a monitor.
+func TestIndicatorHook(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	m := monitor.New()
+	defer m.Stop()
+	l := logger.New(logger.NewTestWriter())
+
+	l.AddHook("monitor", logger.AllLevels, logger.NewIndicatorHook(m, "logger."))
+	l.Error("one")
+	l.Error("two")
+	l.Warning("three")
+
+	// Stay-set indicators start with 1.
+	iv, err := m.StaySetIndicator().Read("logger.error")
+	assert.Nil(err)
+	assert.Equal(iv.Current, 3)
+	iv, err = m.StaySetIndicator().Read("logger.warning")
+	assert.Nil(err)
+	assert.Equal(iv.Current, 2)
+	_, err = m.StaySetIndicator().Read("logger.info")
+	assert.ErrorContains(err, "does not exist")
+}
+
+// EOF
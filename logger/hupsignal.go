@@ -0,0 +1,37 @@
+// Tideland Go Trace - Logger - HUP Signal
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+//go:build !js && !wasip1 && !plan9
+// +build !js,!wasip1,!plan9
+
+package logger // import "tideland.dev/go/trace/logger"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+//--------------------
+// HUP SIGNAL
+//--------------------
+
+// notifyHUP relays the SIGHUP signals to the channel.
+func notifyHUP(c chan os.Signal) {
+	signal.Notify(c, syscall.SIGHUP)
+}
+
+// stopHUP stops relaying the SIGHUP signals to the channel.
+func stopHUP(c chan os.Signal) {
+	signal.Stop(c)
+}
+
+// EOF
@@ -0,0 +1,89 @@
+// Tideland Go Trace - Logger - JSON Writer
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger // import "tideland.dev/go/trace/logger"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+//--------------------
+// JSON WRITER
+//--------------------
+
+// NewJSONWriter creates a writer emitting one JSON object per
+// entry to the passed output. The objects contain the timestamp,
+// the level, the message, the location if known, and the fields.
+func NewJSONWriter(out io.Writer) Writer {
+	return NewFormatWriter(out, NewJSONFormatter())
+}
+
+// NewJSONOutWriter creates a JSON writer writing to STDOUT.
+func NewJSONOutWriter() Writer {
+	return NewJSONWriter(os.Stdout)
+}
+
+// marshalEntry renders an entry as JSON object keeping the
+// order of the fields.
+func marshalEntry(entry Entry) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`{"time":`)
+	writeJSONValue(&buf, entry.Time.Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeJSONValue(&buf, levelToText(entry.Level))
+	buf.WriteString(`,"message":`)
+	writeJSONValue(&buf, entry.Message)
+	if entry.Location.ID != "" {
+		buf.WriteString(`,"location":{"package":`)
+		writeJSONValue(&buf, entry.Location.Package)
+		buf.WriteString(`,"file":`)
+		writeJSONValue(&buf, entry.Location.File)
+		buf.WriteString(`,"func":`)
+		writeJSONValue(&buf, entry.Location.Func)
+		buf.WriteString(`,"line":`)
+		writeJSONValue(&buf, entry.Location.Line)
+		buf.WriteString(`}`)
+	}
+	if len(entry.Fields) > 0 {
+		buf.WriteString(`,"fields":{`)
+		for i, f := range entry.Fields {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSONValue(&buf, f.Key)
+			buf.WriteByte(':')
+			writeJSONValue(&buf, f.Value)
+		}
+		buf.WriteString(`}`)
+	}
+	buf.WriteString(`}`)
+	return buf.Bytes()
+}
+
+// writeJSONValue marshals one value. Errors are written as their
+// message, values not marshallable as their printed representation.
+func writeJSONValue(buf *bytes.Buffer, value interface{}) {
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		b, _ = json.Marshal(fmt.Sprint(value))
+	}
+	buf.Write(b)
+}
+
+// EOF
@@ -0,0 +1,75 @@
+// Tideland Go Trace - Logger - Unit Tests
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/trace/logger"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestJSONWriter tests writing entries as JSON lines.
+func TestJSONWriter(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	buf := &bytes.Buffer{}
+	cw := logger.SetWriter(logger.NewJSONWriter(buf))
+	defer logger.SetWriter(cw)
+
+	logger.SetLevel(logger.LevelDebug)
+	logger.Infof("formatted %q", "text")
+	logger.Critical("structured", "user", "foo", "n", 42, "err", errors.New("ouch"))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Length(lines, 2)
+
+	var first map[string]interface{}
+	err := json.Unmarshal([]byte(lines[0]), &first)
+	assert.Nil(err)
+	assert.Equal(first["level"], "INFO")
+	assert.Equal(first["message"], `formatted "text"`)
+	assert.Nil(first["location"])
+	assert.Nil(first["fields"])
+
+	var second struct {
+		Time     string `json:"time"`
+		Level    string `json:"level"`
+		Message  string `json:"message"`
+		Location struct {
+			Package string `json:"package"`
+			Func    string `json:"func"`
+			Line    int    `json:"line"`
+		} `json:"location"`
+		Fields map[string]interface{} `json:"fields"`
+	}
+	err = json.Unmarshal([]byte(lines[1]), &second)
+	assert.Nil(err)
+	assert.Equal(second.Level, "CRITICAL")
+	assert.Equal(second.Message, "structured")
+	assert.Equal(second.Location.Package, "tideland.dev/go/trace/logger_test")
+	assert.Equal(second.Location.Func, "TestJSONWriter")
+	assert.True(second.Location.Line > 0)
+	assert.Equal(second.Fields["user"], "foo")
+	assert.Equal(second.Fields["n"], 42.0)
+	assert.Equal(second.Fields["err"], "ouch")
+	assert.Contains(`"fields":{"user":"foo","n":42,"err":"ouch"}`, lines[1])
+}
+
+// EOF
@@ -0,0 +1,83 @@
+// Tideland Go Trace - Logger - Levels
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger // import "tideland.dev/go/trace/logger"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"strings"
+
+	"tideland.dev/go/trace/failure"
+)
+
+//--------------------
+// LEVEL TEXT
+//--------------------
+
+// levelAliases maps lower-case level names and syslog-style
+// aliases to the log levels.
+var levelAliases = map[string]LogLevel{
+	"debug":       LevelDebug,
+	"info":        LevelInfo,
+	"information": LevelInfo,
+	"notice":      LevelInfo,
+	"warning":     LevelWarning,
+	"warn":        LevelWarning,
+	"error":       LevelError,
+	"err":         LevelError,
+	"critical":    LevelCritical,
+	"crit":        LevelCritical,
+	"alert":       LevelCritical,
+	"fatal":       LevelFatal,
+	"emerg":       LevelFatal,
+	"emergency":   LevelFatal,
+	"panic":       LevelFatal,
+}
+
+// ParseLevel parses a case-insensitive level name like "info" or
+// "WARNING". Syslog-style aliases like "warn", "err", "crit", or
+// "emerg" are accepted too.
+func ParseLevel(text string) (LogLevel, error) {
+	level, ok := levelAliases[strings.ToLower(strings.TrimSpace(text))]
+	if !ok {
+		return 0, failure.New("invalid log level %q", text)
+	}
+	return level, nil
+}
+
+// String implements the fmt.Stringer interface.
+func (l LogLevel) String() string {
+	return levelToText(l)
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (l LogLevel) MarshalText() ([]byte, error) {
+	if _, ok := levelText[l]; !ok {
+		return nil, failure.New("invalid log level %d", int(l))
+	}
+	return []byte(levelToText(l)), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (l *LogLevel) UnmarshalText(text []byte) error {
+	level, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*l = level
+	return nil
+}
+
+// Set implements the flag.Value interface.
+func (l *LogLevel) Set(text string) error {
+	return l.UnmarshalText([]byte(text))
+}
+
+// EOF
@@ -0,0 +1,94 @@
+// Tideland Go Trace - Logger - Unit Tests
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/trace/logger"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestParseLevel tests the parsing of level names and aliases.
+func TestParseLevel(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+
+	tests := []struct {
+		text  string
+		level logger.LogLevel
+	}{
+		{"debug", logger.LevelDebug},
+		{"INFO", logger.LevelInfo},
+		{"Warning", logger.LevelWarning},
+		{"warn", logger.LevelWarning},
+		{"err", logger.LevelError},
+		{" crit ", logger.LevelCritical},
+		{"emerg", logger.LevelFatal},
+		{"fatal", logger.LevelFatal},
+	}
+	for _, test := range tests {
+		level, err := logger.ParseLevel(test.text)
+		assert.Nil(err)
+		assert.Equal(level, test.level)
+	}
+
+	_, err := logger.ParseLevel("loud")
+	assert.ErrorContains(err, `invalid log level "loud"`)
+}
+
+// TestLevelText tests the printing and marshalling of levels.
+func TestLevelText(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+
+	assert.Equal(logger.LevelWarning.String(), "WARNING")
+	assert.Equal(fmt.Sprintf("%v", logger.LevelCritical), "CRITICAL")
+	assert.Equal(logger.LogLevel(42).String(), "INVALID LEVEL")
+
+	config := struct {
+		Level logger.LogLevel `json:"level"`
+	}{}
+	err := json.Unmarshal([]byte(`{"level":"err"}`), &config)
+	assert.Nil(err)
+	assert.Equal(config.Level, logger.LevelError)
+	data, err := json.Marshal(config)
+	assert.Nil(err)
+	assert.Equal(string(data), `{"level":"ERROR"}`)
+
+	err = json.Unmarshal([]byte(`{"level":"loud"}`), &config)
+	assert.ErrorContains(err, "invalid log level")
+	config.Level = logger.LogLevel(42)
+	_, err = json.Marshal(config)
+	assert.ErrorContains(err, "invalid log level 42")
+}
+
+// TestLevelFlag tests the usage of levels as command-line flags.
+func TestLevelFlag(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	level := logger.LevelInfo
+	fs.Var(&level, "level", "log level")
+
+	err := fs.Parse([]string{"-level", "debug"})
+	assert.Nil(err)
+	assert.Equal(level, logger.LevelDebug)
+	assert.Equal(fs.Lookup("level").Value.String(), "DEBUG")
+	assert.Equal(fs.Lookup("level").DefValue, "INFO")
+}
+
+// EOF
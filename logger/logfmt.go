@@ -0,0 +1,261 @@
+// Tideland Go Trace - Logger - Logfmt
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger // import "tideland.dev/go/trace/logger"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"tideland.dev/go/trace/failure"
+	"tideland.dev/go/trace/location"
+)
+
+//--------------------
+// CONSTANTS
+//--------------------
+
+// Keys of the entry attributes in logfmt lines.
+const (
+	logfmtTime     = "ts"
+	logfmtLevel    = "level"
+	logfmtLocation = "loc"
+	logfmtMessage  = "msg"
+)
+
+// invalidLogfmtLevel is the level of parsed entries whose level has
+// been rendered as invalid by the logfmt formatter.
+const invalidLogfmtLevel LogLevel = -1
+
+// maxLogfmtLine is the maximum length of lines read by ReadLogfmt().
+const maxLogfmtLine = 1024 * 1024
+
+//--------------------
+// LOGFMT FORMATTER
+//--------------------
+
+// NewLogfmtFormatter creates a formatter rendering the entries as
+// logfmt lines with the keys "ts", "level", "loc", and "msg" followed
+// by the fields. The location is only rendered if known. Values, also
+// those of level and location, are quoted if needed, the message always. Invalid characters in keys are
+// replaced by an underscore.
+func NewLogfmtFormatter() Formatter {
+	return FormatterFunc(func(entry Entry) ([]byte, error) {
+		var buf bytes.Buffer
+		buf.WriteString(logfmtTime + "=")
+		buf.WriteString(entry.Time.Format(time.RFC3339Nano))
+		buf.WriteString(" " + logfmtLevel + "=")
+		buf.WriteString(quoteValue(strings.ToLower(levelToText(entry.Level))))
+		if entry.Location.ID != "" {
+			buf.WriteString(" " + logfmtLocation + "=")
+			buf.WriteString(quoteValue(fmt.Sprintf("%s:%s:%s:%d", entry.Location.Package,
+				entry.Location.File, entry.Location.Func, entry.Location.Line)))
+		}
+		buf.WriteString(" " + logfmtMessage + "=")
+		buf.WriteString(strconv.Quote(entry.Message))
+		for _, f := range entry.Fields {
+			buf.WriteByte(' ')
+			buf.WriteString(logfmtKey(f.Key))
+			buf.WriteByte('=')
+			buf.WriteString(quoteValue(f.Value))
+		}
+		buf.WriteByte('\n')
+		return buf.Bytes(), nil
+	})
+}
+
+// NewLogfmtWriter creates a writer emitting one logfmt line per
+// entry to the passed output.
+func NewLogfmtWriter(out io.Writer) Writer {
+	return NewFormatWriter(out, NewLogfmtFormatter())
+}
+
+// NewLogfmtOutWriter creates a logfmt writer writing to STDOUT.
+func NewLogfmtOutWriter() Writer {
+	return NewLogfmtWriter(os.Stdout)
+}
+
+// logfmtKey replaces the characters not allowed in logfmt keys.
+func logfmtKey(key string) string {
+	if key == "" {
+		return badKey
+	}
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' {
+			return '_'
+		}
+		return r
+	}, key)
+}
+
+//--------------------
+// LOGFMT PARSER
+//--------------------
+
+// ParseLogfmt parses a logfmt line into an entry. The first pairs with
+// the keys "ts", "level", "loc", and "msg" set the according attributes,
+// all others are returned as fields with string values. Keys without
+// a value get an empty string. A missing level is interpreted as info,
+// the text of invalid levels written by the formatter is kept invalid.
+func ParseLogfmt(line string) (Entry, error) {
+	entry := Entry{
+		Level: LevelInfo,
+	}
+	seen := map[string]bool{}
+	rest := strings.TrimSpace(line)
+	for rest != "" {
+		key, value, next, err := nextLogfmtPair(rest)
+		if err != nil {
+			return Entry{}, failure.Annotate(err, "invalid logfmt line")
+		}
+		rest = strings.TrimLeft(next, " \t")
+		if !seen[key] {
+			ok, err := entry.setLogfmtAttribute(key, value)
+			if err != nil {
+				return Entry{}, failure.Annotate(err, "invalid logfmt line")
+			}
+			if ok {
+				seen[key] = true
+				continue
+			}
+		}
+		entry.Fields = append(entry.Fields, F(key, value))
+	}
+	return entry, nil
+}
+
+// ReadLogfmt reads all logfmt lines of the reader and returns them
+// as entries. Empty lines are skipped.
+func ReadLogfmt(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLogfmtLine)
+	for no := 1; scanner.Scan(); no++ {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		entry, err := ParseLogfmt(line)
+		if err != nil {
+			return nil, failure.Annotate(err, "cannot read line %d", no)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, failure.Annotate(err, "cannot read logfmt lines")
+	}
+	return entries, nil
+}
+
+// setLogfmtAttribute sets the attribute of the entry with the given key
+// and returns true. Other keys are ignored and false is returned.
+func (e *Entry) setLogfmtAttribute(key, value string) (bool, error) {
+	switch key {
+	case logfmtTime:
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return false, failure.New("invalid time %q", value)
+		}
+		e.Time = t
+	case logfmtLevel:
+		if value == strings.ToLower(levelToText(invalidLogfmtLevel)) {
+			e.Level = invalidLogfmtLevel
+			break
+		}
+		level, err := ParseLevel(value)
+		if err != nil {
+			return false, err
+		}
+		e.Level = level
+	case logfmtLocation:
+		loc, err := parseLogfmtLocation(value)
+		if err != nil {
+			return false, err
+		}
+		e.Location = loc
+	case logfmtMessage:
+		e.Message = value
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// nextLogfmtPair reads the next key and value of a line and returns
+// them together with the remaining line.
+func nextLogfmtPair(line string) (string, string, string, error) {
+	end := strings.IndexAny(line, "= \t")
+	if end == 0 {
+		return "", "", "", failure.New("missing key before %q", line)
+	}
+	if end < 0 {
+		return line, "", "", nil
+	}
+	key := line[:end]
+	if line[end] != '=' {
+		return key, "", line[end:], nil
+	}
+	line = line[end+1:]
+	if !strings.HasPrefix(line, `"`) {
+		end = strings.IndexAny(line, " \t")
+		if end < 0 {
+			return key, line, "", nil
+		}
+		return key, line[:end], line[end:], nil
+	}
+	// Find the closing quote of the value.
+	escaped := false
+	for i := 1; i < len(line); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case line[i] == '\\':
+			escaped = true
+		case line[i] == '"':
+			value, err := strconv.Unquote(line[:i+1])
+			if err != nil {
+				return "", "", "", failure.New("invalid quoted value of key %q", key)
+			}
+			return key, value, line[i+1:], nil
+		}
+	}
+	return "", "", "", failure.New("unterminated quoted value of key %q", key)
+}
+
+// parseLogfmtLocation parses a location in the format rendered by
+// the logfmt formatter.
+func parseLogfmtLocation(text string) (location.Location, error) {
+	parts := strings.Split(text, ":")
+	if len(parts) < 4 {
+		return location.Location{}, failure.New("invalid location %q", text)
+	}
+	n := len(parts)
+	line, err := strconv.Atoi(parts[n-1])
+	if err != nil {
+		return location.Location{}, failure.New("invalid location %q", text)
+	}
+	loc := location.Location{
+		Package: strings.Join(parts[:n-3], ":"),
+		File:    parts[n-3],
+		Func:    parts[n-2],
+		Line:    line,
+	}
+	loc.ID = fmt.Sprintf("(%s:%s:%s:%d)", loc.Package, loc.File, loc.Func, loc.Line)
+	return loc, nil
+}
+
+// EOF
@@ -0,0 +1,129 @@
+// Tideland Go Trace - Logger - Unit Tests
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/trace/location"
+	"tideland.dev/go/trace/logger"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestLogfmtFormatter tests formatting entries as logfmt.
+func TestLogfmtFormatter(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	entry := formatterEntry()
+	entry.Fields = append(entry.Fields, logger.F("bad key", `say "hi"`), logger.F("empty", ""))
+
+	data, err := logger.NewLogfmtFormatter().Format(entry)
+	assert.Nil(err)
+	assert.Equal(string(data), `ts=2020-05-01T12:30:45Z level=warning loc=tideland.dev/go/trace/logger:disk.go:check:42 `+
+		`msg="disk full" path=/var quota="90 %" bad_key="say \"hi\"" empty=""`+"\n")
+}
+
+// TestLogfmtRoundtrip tests writing entries as logfmt and reading
+// them back.
+func TestLogfmtRoundtrip(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	buf := &bytes.Buffer{}
+	l := logger.New(logger.NewLogfmtWriter(buf))
+
+	l.Info("user created", "user", "foo", "n", 42)
+	l.Critical("failed\nbadly", "msg", "shadowed")
+
+	entries, err := logger.ReadLogfmt(buf)
+	assert.Nil(err)
+	assert.Length(entries, 2)
+
+	assert.Equal(entries[0].Level, logger.LevelInfo)
+	assert.Equal(entries[0].Message, "user created")
+	assert.Equal(entries[0].Text(), "user created user=foo n=42")
+	assert.False(entries[0].Time.IsZero())
+
+	assert.Equal(entries[1].Level, logger.LevelCritical)
+	assert.Equal(entries[1].Message, "failed\nbadly")
+	assert.Equal(entries[1].Location.Package, "tideland.dev/go/trace/logger_test")
+	assert.Equal(entries[1].Location.Func, "TestLogfmtRoundtrip")
+	assert.True(entries[1].Location.Line > 0)
+	value, ok := entries[1].Field("msg")
+	assert.True(ok)
+	assert.Equal(value, "shadowed")
+}
+
+// TestLogfmtRoundtripQuoted tests writing and reading back levels
+// and locations containing spaces or equal signs.
+func TestLogfmtRoundtripQuoted(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	entry := formatterEntry()
+	entry.Level = logger.LogLevel(42)
+	entry.Location = location.Location{
+		ID:      "(example.com/app:main test.go:Map[K=string].func1:7)",
+		Package: "example.com/app",
+		File:    "main test.go",
+		Func:    "Map[K=string].func1",
+		Line:    7,
+	}
+	f := logger.NewLogfmtFormatter()
+
+	data, err := f.Format(entry)
+	assert.Nil(err)
+	assert.Contains(`level="invalid level" loc="example.com/app:main test.go:Map[K=string].func1:7" msg="disk full"`, string(data))
+
+	parsed, err := logger.ParseLogfmt(string(data))
+	assert.Nil(err)
+	assert.Equal(parsed.Level.String(), "INVALID LEVEL")
+	assert.Equal(parsed.Location, entry.Location)
+	assert.Equal(parsed.Message, entry.Message)
+	assert.Equal(parsed.Text(), entry.Text())
+	again, err := f.Format(parsed)
+	assert.Nil(err)
+	assert.Equal(string(again), string(data))
+}
+
+// TestParseLogfmt tests parsing foreign and invalid logfmt lines.
+func TestParseLogfmt(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+
+	entry, err := logger.ParseLogfmt(`msg=hello  flag   level=warn path=a=b`)
+	assert.Nil(err)
+	assert.Equal(entry.Level, logger.LevelWarning)
+	assert.Equal(entry.Message, "hello")
+	assert.True(entry.Time.IsZero())
+	assert.Equal(entry.Fields, logger.Fields{logger.F("flag", ""), logger.F("path", "a=b")})
+
+	tests := []struct {
+		line string
+		msg  string
+	}{
+		{`ts=yesterday`, `invalid time "yesterday"`},
+		{`level=loud`, `invalid log level "loud"`},
+		{`loc=main.go:42`, `invalid location "main.go:42"`},
+		{`msg="open`, `unterminated quoted value of key "msg"`},
+		{`=value`, `missing key before "=value"`},
+	}
+	for _, test := range tests {
+		_, err := logger.ParseLogfmt(test.line)
+		assert.ErrorContains(err, test.msg)
+	}
+
+	_, err = logger.ReadLogfmt(strings.NewReader("msg=one\n\nlevel=loud\n"))
+	assert.ErrorContains(err, "cannot read line 3")
+}
+
+// EOF
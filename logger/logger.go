@@ -15,6 +15,7 @@ import (
 	"fmt"
 	"os"
 	"sync"
+	"time"
 
 	"tideland.dev/go/trace/location"
 )
@@ -56,6 +57,20 @@ func PanicFatalExiter() {
 	panic("program aborted after fatal situation, see log")
 }
 
+//--------------------
+// ERRORS
+//--------------------
+
+// ErrorHandlerFunc is called with the errors returned by the writer of
+// a logger, e.g. the collected ones of a multi writer.
+type ErrorHandlerFunc func(err error)
+
+// StderrErrorHandler writes the errors to stderr. It is the default
+// error handler of loggers.
+func StderrErrorHandler(err error) {
+	fmt.Fprintf(os.Stderr, "logger: cannot write entry: %v\n", err)
+}
+
 //--------------------
 // FILTER
 //--------------------
@@ -66,145 +81,513 @@ func PanicFatalExiter() {
 type FilterFunc func(level LogLevel, msg string) bool
 
 //--------------------
-// LOGGER API
+// LOGGER
 //--------------------
 
+// Logger is one logger instance with its own level, writer, filter,
+// and fatal exiter. Child loggers derived with With() or Named() share
+// these settings with their parent, only fields and name differ.
+type Logger struct {
+	backend *loggerBackend
+	name    string
+	fields  Fields
+	skip    int
+}
+
+// New creates a logger instance writing to the passed writer. It starts
+// with info level and ends with os.Exit(-1) in case of a fatal entry.
+// If out is nil the logger writes to stdout.
+func New(out Writer) *Logger {
+	if out == nil {
+		out = NewStandardOutWriter()
+	}
+	return &Logger{
+		backend: &loggerBackend{
+			level:           LevelInfo,
+			out:             AdaptWriter(out),
+			fatalExiter:     OSFatalExiter,
+			errorHandler:    StderrErrorHandler,
+			locationLevels:  DefaultLocationLevels,
+			shutdownTimeout: defaultShutdownTimeout,
+		},
+	}
+}
+
+// Default returns the default logger used by the package functions.
+func Default() *Logger {
+	return std
+}
+
+// Name returns the name of the logger.
+func (l *Logger) Name() string {
+	return l.name
+}
+
+// With returns a child logger adding the passed fields to all entries.
+// Fields are passed as alternating keys and values or as Field.
+func (l *Logger) With(fields ...interface{}) *Logger {
+	cfs := make(Fields, 0, len(l.fields)+len(fields)/2)
+	cfs = append(cfs, l.fields...)
+	cfs = append(cfs, toFields(fields...)...)
+	return &Logger{
+		backend: l.backend,
+		name:    l.name,
+		fields:  cfs,
+		skip:    l.skip,
+	}
+}
+
+// Named returns a child logger with the passed name appended to the
+// name of this one, separated by a dot. The name is logged as field
+// "logger".
+func (l *Logger) Named(name string) *Logger {
+	cname := name
+	if l.name != "" {
+		cname = l.name + "." + name
+	}
+	return &Logger{
+		backend: l.backend,
+		name:    cname,
+		fields:  l.fields,
+		skip:    l.skip,
+	}
+}
+
 // Level returns the current log level.
-func Level() LogLevel {
-	backend.mu.RLock()
-	defer backend.mu.RUnlock()
-	return backend.level
+func (l *Logger) Level() LogLevel {
+	l.backend.mu.RLock()
+	defer l.backend.mu.RUnlock()
+	return l.backend.level
 }
 
 // SetLevel sets the log level to a new one and returns the current.
-func SetLevel(level LogLevel) LogLevel {
-	backend.mu.Lock()
-	defer backend.mu.Unlock()
-	current := backend.level
+func (l *Logger) SetLevel(level LogLevel) LogLevel {
+	l.backend.mu.Lock()
+	defer l.backend.mu.Unlock()
+	current := l.backend.level
 	switch {
 	case level <= LevelDebug:
-		backend.level = LevelDebug
+		l.backend.level = LevelDebug
 	case level >= LevelFatal:
-		backend.level = LevelFatal
+		l.backend.level = LevelFatal
 	default:
-		backend.level = level
+		l.backend.level = level
 	}
 	return current
 }
 
 // SetWriter sets the writing target to a new one and returns the current.
-func SetWriter(out Writer) Writer {
-	backend.mu.Lock()
-	defer backend.mu.Unlock()
-	current := backend.out
+func (l *Logger) SetWriter(out Writer) Writer {
+	l.backend.mu.Lock()
+	defer l.backend.mu.Unlock()
+	current := unadaptWriter(l.backend.out)
 	if out != nil {
-		backend.out = out
+		l.backend.out = AdaptWriter(out)
 	}
 	return current
 }
 
 // SetFatalExiter sets the fatal exiter function to a new one and returns the current.
-func SetFatalExiter(fef FatalExiterFunc) FatalExiterFunc {
-	backend.mu.Lock()
-	defer backend.mu.Unlock()
-	current := backend.fatalExiter
+func (l *Logger) SetFatalExiter(fef FatalExiterFunc) FatalExiterFunc {
+	l.backend.mu.Lock()
+	defer l.backend.mu.Unlock()
+	current := l.backend.fatalExiter
 	if fef != nil {
-		backend.fatalExiter = fef
+		l.backend.fatalExiter = fef
 	}
 	return current
 }
 
+// SetErrorHandler sets the handler for the errors of the writer and
+// returns the current one. Passing nil ignores the errors.
+func (l *Logger) SetErrorHandler(ehf ErrorHandlerFunc) ErrorHandlerFunc {
+	l.backend.mu.Lock()
+	defer l.backend.mu.Unlock()
+	current := l.backend.errorHandler
+	l.backend.errorHandler = ehf
+	return current
+}
+
+// SetFilter sets the output filter to a new one and returns the current.
+// Nil function is allowed, it unsets the filter.
+func (l *Logger) SetFilter(ff FilterFunc) FilterFunc {
+	l.backend.mu.Lock()
+	defer l.backend.mu.Unlock()
+	current := l.backend.shallWrite
+	l.backend.shallWrite = ff
+	return current
+}
+
+// UnsetFilter removes the output filter and returns the current.
+func (l *Logger) UnsetFilter() FilterFunc {
+	return l.SetFilter(nil)
+}
+
+// Debugf logs a message at debug level.
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.logf(LevelDebug, 1, format, args...)
+}
+
+// Infof logs a message at info level.
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.logf(LevelInfo, 1, format, args...)
+}
+
+// Warningf logs a message at warning level.
+func (l *Logger) Warningf(format string, args ...interface{}) {
+	l.logf(LevelWarning, 1, format, args...)
+}
+
+// Errorf logs a message at error level.
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.logf(LevelError, 1, format, args...)
+}
+
+// Criticalf logs a message at critical level.
+func (l *Logger) Criticalf(format string, args ...interface{}) {
+	l.logf(LevelCritical, 1, format, args...)
+}
+
+// Fatalf logs a message at fatal level. After logging the message the
+// method calls the fatal exiter function.
+func (l *Logger) Fatalf(format string, args ...interface{}) {
+	l.logf(LevelFatal, 1, format, args...)
+	l.backend.fatal()
+}
+
+// Debug logs a message with structured fields at debug level. The
+// fields are passed as alternating keys and values or as Field.
+func (l *Logger) Debug(msg string, fields ...interface{}) {
+	l.log(LevelDebug, 1, msg, fields)
+}
+
+// Info logs a message with structured fields at info level.
+func (l *Logger) Info(msg string, fields ...interface{}) {
+	l.log(LevelInfo, 1, msg, fields)
+}
+
+// Warning logs a message with structured fields at warning level.
+func (l *Logger) Warning(msg string, fields ...interface{}) {
+	l.log(LevelWarning, 1, msg, fields)
+}
+
+// Error logs a message with structured fields at error level.
+func (l *Logger) Error(msg string, fields ...interface{}) {
+	l.log(LevelError, 1, msg, fields)
+}
+
+// Critical logs a message with structured fields at critical level.
+func (l *Logger) Critical(msg string, fields ...interface{}) {
+	l.log(LevelCritical, 1, msg, fields)
+}
+
+// Fatal logs a message with structured fields at fatal level. Afterwards
+// the fatal exiter function is called like in Fatalf.
+func (l *Logger) Fatal(msg string, fields ...interface{}) {
+	l.log(LevelFatal, 1, msg, fields)
+	l.backend.fatal()
+}
+
+// logf checks the level before formatting and logging the message. The
+// offset is the one of the location the entry is logged for.
+func (l *Logger) logf(level LogLevel, offset int, format string, args ...interface{}) {
+	loc, pc, fr, ok := l.backend.admit(level, offset+l.skip+1)
+	if !ok && fr == nil {
+		// Passed level is too low.
+		return
+	}
+	l.emit(Entry{
+		Time:     time.Now(),
+		Level:    level,
+		Location: loc,
+		Message:  fmt.Sprintf(format, l.backend.redactArgs(args)...),
+		Fields:   l.entryFields(nil),
+		pc:       pc,
+		template: format,
+	}, fr, ok)
+}
+
+// log checks the level before logging the message with its fields.
+func (l *Logger) log(level LogLevel, offset int, msg string, fields []interface{}) {
+	loc, pc, fr, ok := l.backend.admit(level, offset+l.skip+1)
+	if !ok && fr == nil {
+		// Passed level is too low.
+		return
+	}
+	l.emit(Entry{
+		Time:     time.Now(),
+		Level:    level,
+		Location: loc,
+		Message:  msg,
+		Fields:   l.entryFields(fields),
+		pc:       pc,
+		template: msg,
+	}, fr, ok)
+}
+
+// emit writes the admitted entry or records it if its level is too low.
+func (l *Logger) emit(entry Entry, fr *flightRecorder, ok bool) {
+	if !ok {
+		// Passed level is too low but recorded.
+		fr.record(entry)
+		return
+	}
+	l.backend.write(entry)
+}
+
+// entryFields combines name, logger fields, and the passed ones.
+func (l *Logger) entryFields(fields []interface{}) Fields {
+	if l.name == "" && len(l.fields) == 0 {
+		return toFields(fields...)
+	}
+	efs := make(Fields, 0, len(l.fields)+len(fields)/2+1)
+	if l.name != "" {
+		efs = append(efs, F("logger", l.name))
+	}
+	efs = append(efs, l.fields...)
+	return append(efs, toFields(fields...)...)
+}
+
+//--------------------
+// LOGGER API
+//--------------------
+
+// Level returns the current log level.
+func Level() LogLevel {
+	return std.Level()
+}
+
+// SetLevel sets the log level to a new one and returns the current.
+func SetLevel(level LogLevel) LogLevel {
+	return std.SetLevel(level)
+}
+
+// SetWriter sets the writing target to a new one and returns the current.
+func SetWriter(out Writer) Writer {
+	return std.SetWriter(out)
+}
+
+// SetFatalExiter sets the fatal exiter function to a new one and returns the current.
+func SetFatalExiter(fef FatalExiterFunc) FatalExiterFunc {
+	return std.SetFatalExiter(fef)
+}
+
+// SetErrorHandler sets the handler for the errors of the global writer
+// and returns the current one.
+func SetErrorHandler(ehf ErrorHandlerFunc) ErrorHandlerFunc {
+	return std.SetErrorHandler(ehf)
+}
+
 // SetFilter sets the global output filter to a new one and returns the current.
 // Nil function is allowed, it unsets the filter.
 func SetFilter(ff FilterFunc) FilterFunc {
-	backend.mu.Lock()
-	defer backend.mu.Unlock()
-	current := backend.shallWrite
-	backend.shallWrite = ff
-	return current
+	return std.SetFilter(ff)
 }
 
 // UnsetFilter removes the global output filter and returns the current.
 func UnsetFilter() FilterFunc {
-	return SetFilter(nil)
+	return std.UnsetFilter()
+}
+
+// With returns a child of the default logger adding the passed fields.
+func With(fields ...interface{}) *Logger {
+	return std.With(fields...)
+}
+
+// Named returns a named child of the default logger.
+func Named(name string) *Logger {
+	return std.Named(name)
 }
 
 // Debugf logs a message at debug level.
 func Debugf(format string, args ...interface{}) {
-	backend.log(LevelDebug, location.At(1).ID+" "+format, args...)
+	std.logf(LevelDebug, 1, format, args...)
 }
 
 // Infof logs a message at info level.
 func Infof(format string, args ...interface{}) {
-	backend.log(LevelInfo, format, args...)
+	std.logf(LevelInfo, 1, format, args...)
 }
 
 // Warningf logs a message at warning level.
 func Warningf(format string, args ...interface{}) {
-	backend.log(LevelWarning, format, args...)
+	std.logf(LevelWarning, 1, format, args...)
 }
 
 // Errorf logs a message at error level.
 func Errorf(format string, args ...interface{}) {
-	backend.log(LevelError, format, args...)
+	std.logf(LevelError, 1, format, args...)
 }
 
 // Criticalf logs a message at critical level.
 func Criticalf(format string, args ...interface{}) {
-	backend.log(LevelCritical, location.At(1).ID+" "+format, args...)
+	std.logf(LevelCritical, 1, format, args...)
 }
 
 // Fatalf logs a message at fatal level. After logging the message the
 // function calls the fatal exiter function, which by default means exiting
 // the application with error code -1. So only call in real fatal cases.
 func Fatalf(format string, args ...interface{}) {
-	backend.log(LevelFatal, location.At(1).ID+" "+format, args...)
-	backend.mu.Lock()
-	defer backend.mu.Unlock()
-	backend.fatalExiter()
+	std.logf(LevelFatal, 1, format, args...)
+	std.backend.fatal()
+}
+
+// Debug logs a message with structured fields at debug level. The
+// fields are passed as alternating keys and values or as Field.
+func Debug(msg string, fields ...interface{}) {
+	std.log(LevelDebug, 1, msg, fields)
+}
+
+// Info logs a message with structured fields at info level.
+func Info(msg string, fields ...interface{}) {
+	std.log(LevelInfo, 1, msg, fields)
+}
+
+// Warning logs a message with structured fields at warning level.
+func Warning(msg string, fields ...interface{}) {
+	std.log(LevelWarning, 1, msg, fields)
+}
+
+// Error logs a message with structured fields at error level.
+func Error(msg string, fields ...interface{}) {
+	std.log(LevelError, 1, msg, fields)
+}
+
+// Critical logs a message with structured fields at critical level.
+func Critical(msg string, fields ...interface{}) {
+	std.log(LevelCritical, 1, msg, fields)
+}
+
+// Fatal logs a message with structured fields at fatal level. Afterwards
+// the fatal exiter function is called like in Fatalf.
+func Fatal(msg string, fields ...interface{}) {
+	std.log(LevelFatal, 1, msg, fields)
+	std.backend.fatal()
 }
 
 //--------------------
 // LOGGER IMPLEMENTATION
 //--------------------
 
-// loggerBackend provides a flexible configurable logging system.
+// loggerBackend contains the settings shared by a logger and its children.
 type loggerBackend struct {
-	mu          sync.RWMutex
-	level       LogLevel
-	out         Writer
-	fatalExiter FatalExiterFunc
-	shallWrite  FilterFunc
+	mu           sync.RWMutex
+	level        LogLevel
+	out          EntryWriter
+	fatalExiter  FatalExiterFunc
+	errorHandler ErrorHandlerFunc
+	shallWrite   FilterFunc
+	overrides    levelOverrides
+	hooks        entryHooks
+	recorder     *flightRecorder
+	redactor     *redactor
+	sampler      *sampler
+	samplerStopc chan struct{}
+
+	locationLevels LevelMask
+
+	shutdownHooks   shutdownHooks
+	shutdownTimeout time.Duration
 }
 
-// log checks level and filter and performs the logging.
-func (lb *loggerBackend) log(level LogLevel, format string, args ...interface{}) {
-	// Copies to not block the logger.
+// admit checks if the passed level will be logged for the location
+// at the given offset. The program counter of the caller is only
+// retrieved if needed for the level overrides, the location levels,
+// or the sampling. The location is only returned if the location levels
+// contain the level. Additionally the flight recorder is returned if set.
+func (lb *loggerBackend) admit(level LogLevel, offset int) (location.Location, uintptr, *flightRecorder, bool) {
 	lb.mu.RLock()
 	lbLevel := lb.level
-	lbShallWrite := lb.shallWrite
+	lbOverrides := lb.overrides
+	lbRecorder := lb.recorder
+	lbLocationLevels := lb.locationLevels
+	lbSampling := lb.sampler != nil
 	lb.mu.RUnlock()
-	if lbLevel > level {
-		// Passed level is too low.
+	withLocation := lbLocationLevels.Contains(level)
+	var pc uintptr
+	var loc location.Location
+	if len(lbOverrides) > 0 || withLocation {
+		pc = callerPC(offset + 1)
+		loc = location.ForPC(pc)
+	}
+	if o, ok := lbOverrides.match(loc); ok {
+		lbLevel = o.Level
+	}
+	ok := lbLevel <= level
+	if pc == 0 && lbSampling && (ok || lbRecorder != nil) {
+		pc = callerPC(offset + 1)
+	}
+	if !withLocation {
+		loc = location.Location{}
+	}
+	return loc, pc, lbRecorder, ok
+}
+
+// write samples the entry if configured and delivers it together with
+// the summaries of suppressed similar entries.
+func (lb *loggerBackend) write(entry Entry) {
+	lb.mu.RLock()
+	lbSampler := lb.sampler
+	lb.mu.RUnlock()
+	if lbSampler == nil {
+		lb.deliver(entry)
 		return
 	}
-	msg := fmt.Sprintf(format, args...)
-	if lbShallWrite != nil && !lbShallWrite(level, msg) {
+	ok, summaries := lbSampler.sample(entry)
+	for _, summary := range summaries {
+		lb.deliver(summary)
+	}
+	if ok {
+		lb.deliver(entry)
+	}
+}
+
+// deliver redacts the entry, checks the filter, writes the recorded
+// entries and the entry, and calls the hooks.
+func (lb *loggerBackend) deliver(entry Entry) {
+	// Copy to not block the logger.
+	lb.mu.RLock()
+	lbShallWrite := lb.shallWrite
+	lbHooks := lb.hooks
+	lbRecorder := lb.recorder
+	lbRedactor := lb.redactor
+	lb.mu.RUnlock()
+	raw := entry
+	if lbRedactor != nil {
+		entry = lbRedactor.redact(entry)
+	}
+	if lbShallWrite != nil && !lbShallWrite(entry.Level, entry.Text()) {
 		// Filter rejects log entry.
 		return
 	}
+	if lbRecorder != nil {
+		for _, recorded := range lbRecorder.take(raw) {
+			if lbRedactor != nil {
+				recorded = lbRedactor.redact(recorded)
+			}
+			lb.output(recorded)
+		}
+	}
+	lb.output(entry)
+	// Hooks are called after releasing the lock.
+	lbHooks.call(entry)
+}
+
+// output writes the entry without checking the filter. Errors of the
+// writer are passed to the error handler after releasing the lock.
+func (lb *loggerBackend) output(entry Entry) {
 	lb.mu.Lock()
-	_ = lb.out.Write(level, msg)
+	err := lb.out.WriteEntry(entry)
+	lbErrorHandler := lb.errorHandler
 	lb.mu.Unlock()
+	if err != nil && lbErrorHandler != nil {
+		lbErrorHandler(err)
+	}
 }
 
-// backend provides the logger backend. It is initialised with
+// std provides the default logger. It is initialised with
 // info level, using stdout for writing, and ends with os.Exit(-1)
 // in case of a fatal entry.
-var backend = &loggerBackend{
-	level:       LevelInfo,
-	out:         NewStandardOutWriter(),
-	fatalExiter: OSFatalExiter,
-}
+var std = New(NewStandardOutWriter())
 
 // EOF
@@ -12,6 +12,8 @@ package logger_test
 //--------------------
 
 import (
+	"bytes"
+	"log"
 	"testing"
 
 	"tideland.dev/go/audit/asserts"
@@ -85,6 +87,86 @@ func TestFiltering(t *testing.T) {
 	tw.Reset()
 }
 
+// TestStructuredFields tests logging with structured fields.
+func TestStructuredFields(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	tw := logger.NewTestWriter()
+	cw := logger.SetWriter(tw)
+	defer logger.SetWriter(cw)
+
+	logger.SetLevel(logger.LevelDebug)
+	logger.Info("user created", "user", 4711, "tenant", "acme corp")
+	logger.Warning("fields", logger.F("ok", true), "dangling")
+	logger.Error("no fields")
+	logger.Debug("debug", "n", 1)
+
+	assert.Length(tw, 4)
+	es := tw.Entries()
+	assert.Contains(`[INFO] user created user=4711 tenant="acme corp"`, es[0])
+	assert.Contains(`[WARNING] fields ok=true !BADKEY=dangling`, es[1])
+	assert.Contains(`[ERROR] no fields`, es[2])
+	assert.Contains(`TestStructuredFields`, es[3])
+	assert.Contains(`debug n=1`, es[3])
+}
+
+// TestWriterAdapter tests the adapting of simple writers.
+func TestWriterAdapter(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	sw := &simpleWriter{}
+	cw := logger.SetWriter(sw)
+	defer logger.SetWriter(cw)
+
+	logger.SetLevel(logger.LevelInfo)
+	logger.Info("adapted", "a", 1, "b", "x y")
+	logger.Infof("formatted %d", 2)
+
+	assert.Equal(sw.msgs, []string{`adapted a=1 b="x y"`, "formatted 2"})
+	assert.Equal(logger.SetWriter(cw), sw)
+}
+
+// TestInstances tests independent logger instances and their children.
+func TestInstances(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	twa := logger.NewTestWriter()
+	twb := logger.NewTestWriter()
+	la := logger.New(twa)
+	lb := logger.New(twb)
+
+	la.SetLevel(logger.LevelDebug)
+	lb.SetLevel(logger.LevelError)
+	assert.Equal(la.Level(), logger.LevelDebug)
+	assert.Equal(lb.Level(), logger.LevelError)
+
+	la.Debugf("Debug.")
+	lb.Debugf("Debug.")
+	lb.Errorf("Error.")
+	assert.Length(twa, 1)
+	assert.Length(twb, 1)
+
+	db := la.Named("db").With("tenant", "acme")
+	query := db.Named("query").With(logger.F("table", "users"))
+	assert.Equal(query.Name(), "db.query")
+	db.Info("connected")
+	query.Warning("slow", "ms", 1200)
+
+	es := twa.Entries()
+	assert.Length(es, 3)
+	assert.Contains("connected logger=db tenant=acme", es[1])
+	assert.Contains("slow logger=db.query tenant=acme table=users ms=1200", es[2])
+
+	// Children share the settings.
+	query.SetLevel(logger.LevelWarning)
+	assert.Equal(la.Level(), logger.LevelWarning)
+
+	exited := false
+	db.SetFatalExiter(func() {
+		exited = true
+	})
+	la.Fatal("Fatal.")
+	assert.True(exited)
+	assert.Length(twa, 4)
+}
+
 // TestGoLogger tests logging with the go logger.
 func TestGoLogger(t *testing.T) {
 	cw := logger.SetWriter(logger.NewGoWriter())
@@ -97,11 +179,39 @@ func TestGoLogger(t *testing.T) {
 	logger.Criticalf("Critical.")
 }
 
+// TestGoFormatLogger tests logging with the go logger and a formatter.
+func TestGoFormatLogger(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	var buf bytes.Buffer
+	out, flags := log.Writer(), log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	defer func() {
+		log.SetOutput(out)
+		log.SetFlags(flags)
+	}()
+	f, err := logger.NewTemplateFormatter("{{lower .Level}}: {{.Message}}")
+	assert.Nil(err)
+	l := logger.New(logger.NewGoFormatWriter(f))
+
+	l.Info("one")
+	l.Warning("two")
+	assert.Equal(buf.String(), "info: one\nwarning: two\n")
+
+	buf.Reset()
+	l.SetWriter(logger.NewGoWriter())
+	l.Error("three")
+	assert.Equal(buf.String(), "[ERROR] three\n")
+}
+
 // TestSysLogger tests logging with the syslogger.
 func TestSysLogger(t *testing.T) {
 	assert := asserts.NewTesting(t, asserts.FailStop)
 	sw, err := logger.NewSysWriter("GOTRACELOGGER")
-	assert.Nil(err)
+	if err != nil {
+		assert.ErrorContains(err, "cannot init syslog")
+		t.Skip("no local syslog daemon available")
+	}
 	cw := logger.SetWriter(sw)
 	defer logger.SetWriter(cw)
 
@@ -135,4 +245,19 @@ func TestFatalExit(t *testing.T) {
 	tw.Reset()
 }
 
+//--------------------
+// HELPERS
+//--------------------
+
+// simpleWriter only implements the Writer interface.
+type simpleWriter struct {
+	msgs []string
+}
+
+// Write implements logger.Writer.
+func (w *simpleWriter) Write(level logger.LogLevel, msg string) error {
+	w.msgs = append(w.msgs, msg)
+	return nil
+}
+
 // EOF
@@ -0,0 +1,165 @@
+// Tideland Go Trace - Logger - Multi Writer
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger // import "tideland.dev/go/trace/logger"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"sync"
+	"time"
+
+	"tideland.dev/go/trace/failure"
+)
+
+//--------------------
+// MULTI WRITER
+//--------------------
+
+// Target describes one of the writers of a multi writer. Only entries
+// with at least the given level and accepted by the optional filter
+// are written to it.
+type Target struct {
+	Name   string
+	Writer Writer
+	Level  LogLevel
+	Filter FilterFunc
+}
+
+// MultiWriter dispatches each entry to multiple writers.
+type MultiWriter interface {
+	EntryWriter
+	Flusher
+
+	// Levels returns the minimum levels of the targets by name.
+	Levels() map[string]LogLevel
+
+	// SetTargetLevel sets the minimum level of the named target
+	// and returns the current one.
+	SetTargetLevel(name string, level LogLevel) (LogLevel, error)
+
+	// Close closes all writers implementing io.Closer.
+	Close() error
+}
+
+// multiTarget is the internal representation of a target.
+type multiTarget struct {
+	name   string
+	out    EntryWriter
+	level  LogLevel
+	filter FilterFunc
+}
+
+// multiWriter implements MultiWriter.
+type multiWriter struct {
+	mu      sync.RWMutex
+	targets []*multiTarget
+}
+
+// NewMultiWriter creates a writer dispatching each entry to the
+// passed targets. Errors of the targets are collected.
+func NewMultiWriter(targets ...Target) MultiWriter {
+	w := &multiWriter{}
+	for _, target := range targets {
+		if target.Writer == nil {
+			continue
+		}
+		w.targets = append(w.targets, &multiTarget{
+			name:   target.Name,
+			out:    AdaptWriter(target.Writer),
+			level:  target.Level,
+			filter: target.Filter,
+		})
+	}
+	return w
+}
+
+// Write implements Writer.
+func (w *multiWriter) Write(level LogLevel, msg string) error {
+	return w.WriteEntry(Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: msg,
+	})
+}
+
+// WriteEntry implements EntryWriter.
+func (w *multiWriter) WriteEntry(entry Entry) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	var errs []error
+	var text string
+	for _, target := range w.targets {
+		if entry.Level < target.level {
+			continue
+		}
+		if target.filter != nil {
+			if text == "" {
+				text = entry.Text()
+			}
+			if !target.filter(entry.Level, text) {
+				continue
+			}
+		}
+		if err := target.out.WriteEntry(entry); err != nil {
+			errs = append(errs, failure.Annotate(err, "cannot write to target %q", target.name))
+		}
+	}
+	return failure.Collect(errs...)
+}
+
+// Levels implements MultiWriter.
+func (w *multiWriter) Levels() map[string]LogLevel {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	levels := make(map[string]LogLevel, len(w.targets))
+	for _, target := range w.targets {
+		levels[target.name] = target.level
+	}
+	return levels
+}
+
+// SetTargetLevel implements MultiWriter.
+func (w *multiWriter) SetTargetLevel(name string, level LogLevel) (LogLevel, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, target := range w.targets {
+		if target.name == name {
+			current := target.level
+			target.level = level
+			return current, nil
+		}
+	}
+	return 0, failure.New("target %q not found", name)
+}
+
+// Flush implements Flusher.
+func (w *multiWriter) Flush() error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	var errs []error
+	for _, target := range w.targets {
+		errs = append(errs, failure.Annotate(flushWriter(target.out), "cannot flush target %q", target.name))
+	}
+	return failure.Collect(errs...)
+}
+
+// Close implements MultiWriter.
+func (w *multiWriter) Close() error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	var errs []error
+	for _, target := range w.targets {
+		_, err := closeWriter(target.out)
+		errs = append(errs, failure.Annotate(err, "cannot close target %q", target.name))
+	}
+	return failure.Collect(errs...)
+}
+
+// EOF
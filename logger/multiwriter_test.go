@@ -0,0 +1,125 @@
+// Tideland Go Trace - Logger - Unit Tests
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/trace/failure"
+	"tideland.dev/go/trace/logger"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestMultiWriter tests dispatching to multiple writers.
+func TestMultiWriter(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	all := logger.NewTestWriter()
+	warnings := logger.NewTestWriter()
+	filtered := logger.NewTestWriter()
+	mw := logger.NewMultiWriter(
+		logger.Target{Name: "all", Writer: all, Level: logger.LevelDebug},
+		logger.Target{Name: "warnings", Writer: warnings, Level: logger.LevelWarning},
+		logger.Target{Name: "filtered", Writer: filtered, Level: logger.LevelDebug, Filter: func(level logger.LogLevel, msg string) bool {
+			return strings.Contains(msg, "db")
+		}},
+	)
+	l := logger.New(mw)
+	l.SetLevel(logger.LevelDebug)
+
+	l.Debugf("debug")
+	l.Info("info", "db", "users")
+	l.Warningf("warning")
+	l.Errorf("db error")
+
+	assert.Length(all, 4)
+	assert.Length(warnings, 2)
+	assert.Length(filtered, 2)
+
+	current, err := mw.SetTargetLevel("warnings", logger.LevelError)
+	assert.Nil(err)
+	assert.Equal(current, logger.LevelWarning)
+	_, err = mw.SetTargetLevel("unknown", logger.LevelError)
+	assert.ErrorContains(err, `target "unknown" not found`)
+	assert.Equal(mw.Levels(), map[string]logger.LogLevel{
+		"all":      logger.LevelDebug,
+		"warnings": logger.LevelError,
+		"filtered": logger.LevelDebug,
+	})
+
+	l.Warningf("warning")
+	assert.Length(warnings, 2)
+}
+
+// TestMultiWriterErrors tests the collecting of errors.
+func TestMultiWriterErrors(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	tw := logger.NewTestWriter()
+	mw := logger.NewMultiWriter(
+		logger.Target{Name: "one", Writer: failingWriter{}},
+		logger.Target{Name: "two", Writer: tw},
+		logger.Target{Name: "three", Writer: failingWriter{}},
+	)
+
+	err := mw.Write(logger.LevelInfo, "info")
+	assert.Length(tw, 1)
+	assert.Length(failure.All(err), 2)
+	assert.ErrorContains(err, `cannot write to target "one"`)
+	assert.ErrorContains(err, `cannot write to target "three"`)
+	assert.Nil(mw.Flush())
+	assert.Nil(mw.Close())
+}
+
+// TestMultiWriterErrorHandler tests passing the collected errors
+// to the error handler of the logger.
+func TestMultiWriterErrorHandler(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	tw := logger.NewTestWriter()
+	l := logger.New(logger.NewMultiWriter(
+		logger.Target{Name: "one", Writer: failingWriter{}},
+		logger.Target{Name: "two", Writer: tw},
+	))
+	var errs []error
+	current := l.SetErrorHandler(func(err error) {
+		errs = append(errs, err)
+	})
+	assert.NotNil(current)
+
+	l.Info("info")
+	assert.Length(tw, 1)
+	assert.Length(errs, 1)
+	assert.ErrorContains(errs[0], `cannot write to target "one"`)
+
+	l.SetErrorHandler(nil)
+	l.Info("ignored")
+	assert.Length(tw, 2)
+	assert.Length(errs, 1)
+}
+
+//--------------------
+// HELPERS
+//--------------------
+
+// failingWriter always returns an error.
+type failingWriter struct{}
+
+// Write implements logger.Writer.
+func (w failingWriter) Write(level logger.LogLevel, msg string) error {
+	return errors.New("failing writer")
+}
+
+// EOF
@@ -0,0 +1,32 @@
+// Tideland Go Trace - Logger - No HUP Signal
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+//go:build js || wasip1 || plan9
+// +build js wasip1 plan9
+
+package logger // import "tideland.dev/go/trace/logger"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"os"
+)
+
+//--------------------
+// HUP SIGNAL
+//--------------------
+
+// notifyHUP does nothing on platforms without SIGHUP, so the
+// file is never reopened by signal.
+func notifyHUP(c chan os.Signal) {}
+
+// stopHUP does nothing on platforms without SIGHUP.
+func stopHUP(c chan os.Signal) {}
+
+// EOF
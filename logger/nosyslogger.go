@@ -5,6 +5,7 @@
 // All rights reserved. Use of this source code is governed
 // by the new BSD license.
 
+//go:build windows || plan9 || nacl
 // +build windows plan9 nacl
 
 package logger // import "tideland.dev/go/trace/logger"
@@ -15,24 +16,24 @@ package logger // import "tideland.dev/go/trace/logger"
 
 import (
 	"log"
+	"time"
 )
 
 //--------------------
 // SYSWRITER
 //--------------------
 
-// sysWriter uses the Go syslog package. It does not work
-// on Windows or Plan9.
+// nosyslogWriter replaces the local syslog on Windows or Plan9.
 type nosyslogWriter struct {
 	tag string
 }
 
-// NewSysWriter creates a writer using the Go syslog package.
-// It does not work on Windows or Plan9. Here the Go log
-// package is used.
+// NewSysWriter creates a writer using the local syslog daemon. It
+// does not work on Windows or Plan9, here the Go log package is used.
+// Remote syslog collectors can be used with NewSyslogWriter.
 func NewSysWriter(tag string) (Writer, error) {
 	if len(tag) > 0 {
-		tag = "(" + tag + ")"
+		tag = "(" + tag + ") "
 	}
 	return &nosyslogWriter{
 		tag: tag,
@@ -40,9 +41,19 @@ func NewSysWriter(tag string) (Writer, error) {
 }
 
 // Write implements Writer.
-func (w *nosyslogWriter) Write(level LogLevel, msg string) {
-	text := levelToText(level)
-	log.Println("["+text+"]", msg)
+func (w *nosyslogWriter) Write(level LogLevel, msg string) error {
+	return w.WriteEntry(Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: msg,
+	})
+}
+
+// WriteEntry implements EntryWriter.
+func (w *nosyslogWriter) WriteEntry(entry Entry) error {
+	text := levelToText(entry.Level)
+	log.Println(w.tag+"["+text+"]", entry.Text())
+	return nil
 }
 
 // EOF
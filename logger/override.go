@@ -0,0 +1,185 @@
+// Tideland Go Trace - Logger - Level Overrides
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger // import "tideland.dev/go/trace/logger"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"strings"
+
+	"tideland.dev/go/trace/location"
+)
+
+//--------------------
+// LEVEL OVERRIDE
+//--------------------
+
+// LevelOverride sets a log level for all locations matching its
+// non-empty selectors. Package matches the package and its sub-packages,
+// File the file name, and Func the function name as provided by the
+// location package.
+type LevelOverride struct {
+	Package string   `json:"package,omitempty"`
+	File    string   `json:"file,omitempty"`
+	Func    string   `json:"func,omitempty"`
+	Level   LogLevel `json:"level"`
+}
+
+// sameSelectors checks if both overrides have the same selectors.
+func (o LevelOverride) sameSelectors(other LevelOverride) bool {
+	return o.Package == other.Package && o.File == other.File && o.Func == other.Func
+}
+
+// matches checks if the location matches all selectors.
+func (o LevelOverride) matches(loc location.Location) bool {
+	if o.Package != "" {
+		if loc.Package != o.Package &&
+			loc.Package != o.Package+"_test" &&
+			!strings.HasPrefix(loc.Package, o.Package+"/") {
+			return false
+		}
+	}
+	if o.File != "" && loc.File != o.File {
+		return false
+	}
+	if o.Func != "" && loc.Func != o.Func {
+		return false
+	}
+	return true
+}
+
+// specificity rates how specific the override is. More selectors
+// and longer package paths are more specific.
+func (o LevelOverride) specificity() int {
+	s := len(o.Package)
+	if o.File != "" {
+		s += 1 << 16
+	}
+	if o.Func != "" {
+		s += 1 << 17
+	}
+	return s
+}
+
+// levelOverrides contains the overrides of a logger. It is never changed
+// but replaced, so it can be used without locking after copying.
+type levelOverrides []LevelOverride
+
+// match returns the most specific override matching the location.
+func (ovs levelOverrides) match(loc location.Location) (LevelOverride, bool) {
+	var found LevelOverride
+	var ok bool
+	for _, o := range ovs {
+		if o.matches(loc) && (!ok || o.specificity() > found.specificity()) {
+			found = o
+			ok = true
+		}
+	}
+	return found, ok
+}
+
+// with returns new overrides containing the passed one, replacing an
+// existing one with the same selectors.
+func (ovs levelOverrides) with(o LevelOverride) levelOverrides {
+	nos := make(levelOverrides, 0, len(ovs)+1)
+	for _, eo := range ovs {
+		if !eo.sameSelectors(o) {
+			nos = append(nos, eo)
+		}
+	}
+	return append(nos, o)
+}
+
+// without returns new overrides without the one with the same
+// selectors like the passed one.
+func (ovs levelOverrides) without(o LevelOverride) levelOverrides {
+	nos := make(levelOverrides, 0, len(ovs))
+	for _, eo := range ovs {
+		if !eo.sameSelectors(o) {
+			nos = append(nos, eo)
+		}
+	}
+	if len(nos) == 0 {
+		return nil
+	}
+	return nos
+}
+
+//--------------------
+// LOGGER API
+//--------------------
+
+// SetLevelOverride sets a level for the locations matching the selectors
+// of the override. An existing one with the same selectors is replaced.
+func (l *Logger) SetLevelOverride(o LevelOverride) {
+	l.backend.mu.Lock()
+	defer l.backend.mu.Unlock()
+	l.backend.overrides = l.backend.overrides.with(o)
+}
+
+// UnsetLevelOverride removes the override with the same selectors
+// like the passed one.
+func (l *Logger) UnsetLevelOverride(o LevelOverride) {
+	l.backend.mu.Lock()
+	defer l.backend.mu.Unlock()
+	l.backend.overrides = l.backend.overrides.without(o)
+}
+
+// LevelOverrides returns the currently set level overrides.
+func (l *Logger) LevelOverrides() []LevelOverride {
+	l.backend.mu.RLock()
+	defer l.backend.mu.RUnlock()
+	ovs := make([]LevelOverride, len(l.backend.overrides))
+	copy(ovs, l.backend.overrides)
+	return ovs
+}
+
+// ClearLevelOverrides removes all level overrides.
+func (l *Logger) ClearLevelOverrides() {
+	l.backend.mu.Lock()
+	defer l.backend.mu.Unlock()
+	l.backend.overrides = nil
+}
+
+// SetPackageLevel sets the level for a package and its sub-packages.
+func (l *Logger) SetPackageLevel(pkg string, level LogLevel) {
+	l.SetLevelOverride(LevelOverride{
+		Package: pkg,
+		Level:   level,
+	})
+}
+
+// SetLevelOverride sets a level override of the default logger.
+func SetLevelOverride(o LevelOverride) {
+	std.SetLevelOverride(o)
+}
+
+// UnsetLevelOverride removes a level override of the default logger.
+func UnsetLevelOverride(o LevelOverride) {
+	std.UnsetLevelOverride(o)
+}
+
+// LevelOverrides returns the level overrides of the default logger.
+func LevelOverrides() []LevelOverride {
+	return std.LevelOverrides()
+}
+
+// ClearLevelOverrides removes all level overrides of the default logger.
+func ClearLevelOverrides() {
+	std.ClearLevelOverrides()
+}
+
+// SetPackageLevel sets the level for a package and its sub-packages
+// of the default logger.
+func SetPackageLevel(pkg string, level LogLevel) {
+	std.SetPackageLevel(pkg, level)
+}
+
+// EOF
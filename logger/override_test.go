@@ -0,0 +1,92 @@
+// Tideland Go Trace - Logger - Unit Tests
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/trace/logger"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestLevelOverrides tests the overriding of levels for locations.
+func TestLevelOverrides(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	tw := logger.NewTestWriter()
+	l := logger.New(tw)
+	l.SetLevel(logger.LevelWarning)
+
+	l.Infof("dropped")
+	logFromHelper(l)
+	assert.Length(tw, 0)
+
+	// Override for the whole package.
+	l.SetPackageLevel("tideland.dev/go/trace/logger", logger.LevelDebug)
+	l.Debugf("debug")
+	l.Info("info")
+	logFromHelper(l)
+	assert.Length(tw, 3)
+	tw.Reset()
+
+	// More specific override for the helper function.
+	l.SetLevelOverride(logger.LevelOverride{
+		Package: "tideland.dev/go/trace/logger",
+		Func:    "logFromHelper",
+		Level:   logger.LevelError,
+	})
+	l.Info("info")
+	logFromHelper(l)
+	assert.Length(tw, 1)
+	assert.Length(l.LevelOverrides(), 2)
+	tw.Reset()
+
+	// Other packages and files don't match.
+	l.ClearLevelOverrides()
+	l.SetPackageLevel("tideland.dev/go/trace/log", logger.LevelDebug)
+	l.SetLevelOverride(logger.LevelOverride{
+		File:  "other_test.go",
+		Level: logger.LevelDebug,
+	})
+	l.Info("info")
+	assert.Length(tw, 0)
+
+	// Override for the file.
+	l.SetLevelOverride(logger.LevelOverride{
+		File:  "override_test.go",
+		Level: logger.LevelInfo,
+	})
+	l.Info("info")
+	l.Debug("debug")
+	assert.Length(tw, 1)
+
+	l.UnsetLevelOverride(logger.LevelOverride{
+		File: "override_test.go",
+	})
+	l.Info("info")
+	assert.Length(tw, 1)
+	assert.Length(l.LevelOverrides(), 2)
+}
+
+//--------------------
+// HELPERS
+//--------------------
+
+// logFromHelper logs an info entry.
+func logFromHelper(l *logger.Logger) {
+	l.Infof("from helper")
+}
+
+// EOF
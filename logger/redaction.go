@@ -0,0 +1,253 @@
+// Tideland Go Trace - Logger - Redaction
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger // import "tideland.dev/go/trace/logger"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+//--------------------
+// REDACTION
+//--------------------
+
+// defaultRedactionMask replaces redacted values if no mask is configured.
+const defaultRedactionMask = "[REDACTED]"
+
+// Patterns for secrets and personal data often found in log entries.
+var (
+	PatternCreditCard  = regexp.MustCompile(`\b(?:\d{4}[ -]?){3}\d{1,4}\b`)
+	PatternBearerToken = regexp.MustCompile(`(?i)\bbearer\s+[a-z0-9\-._~+/]+=*`)
+	PatternEmail       = regexp.MustCompile(`\b[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}\b`)
+)
+
+// Redactor is implemented by values knowing how to render themselves
+// in log entries without disclosing secrets.
+type Redactor interface {
+	Redact() string
+}
+
+// RedactionConfig contains the configuration of the redaction.
+type RedactionConfig struct {
+	// Fields contains the names of the fields whose values are masked.
+	// They are compared case-insensitive, also with the last part of
+	// dotted keys like "db.password".
+	Fields []string
+
+	// Patterns contains the regular expressions whose matches are
+	// masked in messages and string values.
+	Patterns []*regexp.Regexp
+
+	// Mask replaces the redacted values, by default "[REDACTED]".
+	Mask string
+}
+
+// DefaultRedactionConfig returns a configuration masking typical secret
+// fields like passwords and tokens as well as credit card numbers,
+// bearer tokens, and email addresses.
+func DefaultRedactionConfig() RedactionConfig {
+	return RedactionConfig{
+		Fields: []string{
+			"password", "passwd", "secret", "token", "access_token",
+			"refresh_token", "api_key", "apikey", "authorization", "cookie",
+		},
+		Patterns: []*regexp.Regexp{
+			PatternCreditCard,
+			PatternBearerToken,
+			PatternEmail,
+		},
+	}
+}
+
+// redactor masks the secrets of entries.
+type redactor struct {
+	fields   map[string]struct{}
+	patterns []*regexp.Regexp
+	mask     string
+}
+
+// newRedactor creates a redactor for the configuration.
+func newRedactor(cfg RedactionConfig) *redactor {
+	r := &redactor{
+		fields:   make(map[string]struct{}, len(cfg.Fields)),
+		patterns: cfg.Patterns,
+		mask:     cfg.Mask,
+	}
+	for _, field := range cfg.Fields {
+		r.fields[strings.ToLower(field)] = struct{}{}
+	}
+	if r.mask == "" {
+		r.mask = defaultRedactionMask
+	}
+	return r
+}
+
+// redact returns a copy of the entry with masked secrets.
+func (r *redactor) redact(entry Entry) Entry {
+	entry.Message = r.redactText(entry.Message)
+	if len(entry.Fields) == 0 {
+		return entry
+	}
+	fields := make(Fields, len(entry.Fields))
+	for i, f := range entry.Fields {
+		fields[i] = F(f.Key, r.redactValue(f.Key, f.Value))
+	}
+	entry.Fields = fields
+	return entry
+}
+
+// redactValue masks the value if the key is configured, it implements
+// Redactor, or its text matches a pattern. The text of byte slices,
+// errors, and fmt.Stringer is checked too.
+func (r *redactor) redactValue(key string, value interface{}) interface{} {
+	if r.isSecret(key) {
+		return r.mask
+	}
+	var text string
+	switch v := value.(type) {
+	case Redactor:
+		return v.Redact()
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	case error:
+		text = v.Error()
+	case fmt.Stringer:
+		text = v.String()
+	default:
+		return value
+	}
+	if redacted := r.redactText(text); redacted != text {
+		return redacted
+	}
+	return value
+}
+
+// isSecret checks if the key or the last part of a dotted key is
+// a configured field.
+func (r *redactor) isSecret(key string) bool {
+	key = strings.ToLower(key)
+	if _, ok := r.fields[key]; ok {
+		return true
+	}
+	if i := strings.LastIndexByte(key, '.'); i >= 0 {
+		_, ok := r.fields[key[i+1:]]
+		return ok
+	}
+	return false
+}
+
+// redactText masks all matches of the patterns.
+func (r *redactor) redactText(text string) string {
+	for _, pattern := range r.patterns {
+		text = pattern.ReplaceAllLiteralString(text, r.mask)
+	}
+	return text
+}
+
+// redactArgs returns the arguments of a formatted message with the
+// values implementing Redactor replaced by their redacted text. The
+// passed arguments are returned if no redaction is set or none of
+// them is a Redactor.
+func (lb *loggerBackend) redactArgs(args []interface{}) []interface{} {
+	lb.mu.RLock()
+	lbRedactor := lb.redactor
+	lb.mu.RUnlock()
+	if lbRedactor == nil {
+		return args
+	}
+	var rargs []interface{}
+	for i, arg := range args {
+		r, ok := arg.(Redactor)
+		if !ok {
+			continue
+		}
+		if rargs == nil {
+			rargs = make([]interface{}, len(args))
+			copy(rargs, args)
+		}
+		rargs[i] = r.Redact()
+	}
+	if rargs == nil {
+		return args
+	}
+	return rargs
+}
+
+// SetRedaction lets the logger mask secrets in the messages and fields
+// of all entries before they are filtered and written.
+func (l *Logger) SetRedaction(cfg RedactionConfig) {
+	l.backend.mu.Lock()
+	defer l.backend.mu.Unlock()
+	l.backend.redactor = newRedactor(cfg)
+}
+
+// UnsetRedaction stops the redaction of the logger.
+func (l *Logger) UnsetRedaction() {
+	l.backend.mu.Lock()
+	defer l.backend.mu.Unlock()
+	l.backend.redactor = nil
+}
+
+// SetRedaction sets the redaction of the default logger.
+func SetRedaction(cfg RedactionConfig) {
+	std.SetRedaction(cfg)
+}
+
+// UnsetRedaction stops the redaction of the default logger.
+func UnsetRedaction() {
+	std.UnsetRedaction()
+}
+
+//--------------------
+// REDACTING WRITER
+//--------------------
+
+// redactingWriter masks secrets before writing the entries.
+type redactingWriter struct {
+	out      EntryWriter
+	redactor *redactor
+}
+
+// NewRedactingWriter creates a writer masking the secrets of the entries
+// before passing them to the wrapped writer. It can be used for writers
+// not only written by a logger, e.g. behind a slog handler.
+func NewRedactingWriter(out Writer, cfg RedactionConfig) Writer {
+	return &redactingWriter{
+		out:      AdaptWriter(out),
+		redactor: newRedactor(cfg),
+	}
+}
+
+// Write implements Writer.
+func (w *redactingWriter) Write(level LogLevel, msg string) error {
+	return w.WriteEntry(Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: msg,
+	})
+}
+
+// WriteEntry implements EntryWriter.
+func (w *redactingWriter) WriteEntry(entry Entry) error {
+	return w.out.WriteEntry(w.redactor.redact(entry))
+}
+
+// unwrap implements wrapper.
+func (w *redactingWriter) unwrap() Writer {
+	return unadaptWriter(w.out)
+}
+
+// EOF
@@ -0,0 +1,138 @@
+// Tideland Go Trace - Logger - Unit Tests
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/trace/logger"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestRedaction tests masking secrets before writing.
+func TestRedaction(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	tw := logger.NewTestWriter()
+	l := logger.New(tw)
+	l.SetRedaction(logger.DefaultRedactionConfig())
+
+	l.Info("login", "user", "foo", "Password", "s3cr3t", "db.token", 1234)
+	l.Infof("paid with 4111 1111 1111 1111 by %s", "foo@example.com")
+	l.Warning("request", "header", "Bearer abc.def-ghi", "err", errors.New("mail to bar@example.org failed"))
+	l.Error("account", "key", apiKey("sk_live_123456"), "attempts", 3)
+
+	es := tw.Entries()
+	assert.Length(es, 4)
+	assert.Contains(`login user=foo Password=[REDACTED] db.token=[REDACTED]`, es[0])
+	assert.Contains(`paid with [REDACTED] by [REDACTED]`, es[1])
+	assert.Contains(`request header=[REDACTED] err="mail to [REDACTED] failed"`, es[2])
+	assert.Contains(`account key=sk_****3456 attempts=3`, es[3])
+
+	// Filters only see the redacted entries.
+	l.SetFilter(func(level logger.LogLevel, msg string) bool {
+		assert.NotContains("s3cr3t", msg)
+		return true
+	})
+	l.Info("again", "password", "s3cr3t")
+	l.UnsetFilter()
+
+	l.UnsetRedaction()
+	l.Info("login", "password", "s3cr3t")
+	assert.Contains("password=s3cr3t", tw.Entries()[5])
+}
+
+// TestRedactionArgs tests the redaction of formatted arguments and
+// of field values not being strings.
+func TestRedactionArgs(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	tw := logger.NewTestWriter()
+	l := logger.New(tw)
+	l.SetRedaction(logger.DefaultRedactionConfig())
+
+	l.Infof("using key %v for %s", apiKey("sk_live_123456"), "billing")
+	l.Infof("using key %s", "sk_live_123456")
+	l.Info("request",
+		"header", []byte("Bearer abc.def-ghi"),
+		"user", mailAddress("foo@example.com"),
+		"err", errors.New("bearer xyz rejected"),
+		"raw", []byte("plain"))
+
+	es := tw.Entries()
+	assert.Length(es, 3)
+	assert.Contains("using key sk_****3456 for billing", es[0])
+	assert.Contains("using key sk_live_123456", es[1])
+	assert.Contains(`request header=[REDACTED] user=<[REDACTED]> err="[REDACTED] rejected"`, es[2])
+
+	l.UnsetRedaction()
+	l.Infof("using key %v", apiKey("sk_live_123456"))
+	assert.Contains("using key sk_live_123456", tw.Entries()[3])
+}
+
+// TestRedactionConfig tests own fields, patterns, and masks.
+func TestRedactionConfig(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	cw := logger.NewCaptureWriter()
+	l := logger.New(cw)
+	l.SetRedaction(logger.RedactionConfig{
+		Fields:   []string{"pin"},
+		Patterns: []*regexp.Regexp{regexp.MustCompile(`ID-\d+`)},
+		Mask:     "###",
+	})
+
+	l.Info("customer ID-4711", "pin", 1234, "password", "visible", "ref", "ID-42")
+	entry := cw.Entries()[0]
+	assert.Equal(entry.Message, "customer ###")
+	pin, _ := entry.Field("pin")
+	assert.Equal(pin, "###")
+	password, _ := entry.Field("password")
+	assert.Equal(password, "visible")
+	ref, _ := entry.Field("ref")
+	assert.Equal(ref, "###")
+}
+
+// TestRedactingWriter tests the wrapping of writers.
+func TestRedactingWriter(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	tw := logger.NewTestWriter()
+	rw := logger.NewRedactingWriter(tw, logger.DefaultRedactionConfig())
+
+	assert.Nil(rw.Write(logger.LevelInfo, "Authorization: Bearer xyz"))
+	assert.Contains("Authorization: [REDACTED]", tw.Entries()[0])
+}
+
+//--------------------
+// HELPERS
+//--------------------
+
+// apiKey is a secret implementing the Redactor interface.
+type apiKey string
+
+// Redact implements logger.Redactor.
+func (k apiKey) Redact() string {
+	return string(k[:3]) + "****" + string(k[len(k)-4:])
+}
+
+// mailAddress is a value implementing fmt.Stringer.
+type mailAddress string
+
+// String implements fmt.Stringer.
+func (m mailAddress) String() string {
+	return "<" + string(m) + ">"
+}
+
+// EOF
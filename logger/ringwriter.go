@@ -0,0 +1,191 @@
+// Tideland Go Trace - Logger - Ring Writer
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger // import "tideland.dev/go/trace/logger"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"sync"
+	"time"
+)
+
+//--------------------
+// RING WRITER
+//--------------------
+
+// defaultRingEntries is the number of kept entries if no limit
+// is configured.
+const defaultRingEntries = 1000
+
+// RingConfig contains the configuration of a ring writer.
+type RingConfig struct {
+	// MaxEntries limits the number of kept entries.
+	MaxEntries int
+
+	// MaxBytes limits the summed size of the texts of the kept
+	// entries. If both limits are zero 1000 entries are kept.
+	MaxBytes int
+}
+
+// RingWriter keeps the last entries in memory.
+type RingWriter interface {
+	EntryWriter
+
+	// Entries returns the kept entries, oldest first.
+	Entries() []Entry
+
+	// Follow returns the kept entries and a channel receiving all
+	// entries written afterwards. Entries are dropped if the channel
+	// buffer is full. The returned function stops following.
+	Follow(buffer int) ([]Entry, <-chan Entry, func())
+
+	// Reset removes all kept entries.
+	Reset()
+}
+
+// ringItem is one kept entry together with the size of its text.
+type ringItem struct {
+	entry Entry
+	size  int
+}
+
+// ringWriter implements RingWriter.
+type ringWriter struct {
+	mu        sync.Mutex
+	cfg       RingConfig
+	ring      []ringItem
+	head      int
+	count     int
+	bytes     int
+	followers map[int]chan Entry
+	followID  int
+}
+
+// NewRingWriter creates a writer keeping the last entries in memory,
+// limited by their number and/or the size of their texts.
+func NewRingWriter(cfg RingConfig) RingWriter {
+	if cfg.MaxEntries <= 0 && cfg.MaxBytes <= 0 {
+		cfg.MaxEntries = defaultRingEntries
+	}
+	w := &ringWriter{
+		cfg:       cfg,
+		followers: make(map[int]chan Entry),
+	}
+	if cfg.MaxEntries > 0 {
+		w.ring = make([]ringItem, cfg.MaxEntries)
+	}
+	return w
+}
+
+// Write implements Writer.
+func (w *ringWriter) Write(level LogLevel, msg string) error {
+	return w.WriteEntry(Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: msg,
+	})
+}
+
+// WriteEntry implements EntryWriter.
+func (w *ringWriter) WriteEntry(entry Entry) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	size := len(entry.Text())
+	if w.cfg.MaxBytes > 0 && size > w.cfg.MaxBytes {
+		// Entry alone exceeds the limit.
+		return nil
+	}
+	if w.count == len(w.ring) {
+		if w.cfg.MaxEntries > 0 {
+			w.pop()
+		} else {
+			w.grow()
+		}
+	}
+	for w.cfg.MaxBytes > 0 && w.bytes+size > w.cfg.MaxBytes {
+		w.pop()
+	}
+	w.ring[(w.head+w.count)%len(w.ring)] = ringItem{entry, size}
+	w.count++
+	w.bytes += size
+	for _, entryc := range w.followers {
+		select {
+		case entryc <- entry:
+		default:
+		}
+	}
+	return nil
+}
+
+// Entries implements RingWriter.
+func (w *ringWriter) Entries() []Entry {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.entries()
+}
+
+// Follow implements RingWriter.
+func (w *ringWriter) Follow(buffer int) ([]Entry, <-chan Entry, func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.followID++
+	id := w.followID
+	entryc := make(chan Entry, buffer)
+	w.followers[id] = entryc
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			delete(w.followers, id)
+			close(entryc)
+		})
+	}
+	return w.entries(), entryc, stop
+}
+
+// Reset implements RingWriter.
+func (w *ringWriter) Reset() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for w.count > 0 {
+		w.pop()
+	}
+	w.head = 0
+}
+
+// entries returns a copy of the kept entries.
+func (w *ringWriter) entries() []Entry {
+	entries := make([]Entry, w.count)
+	for i := range entries {
+		entries[i] = w.ring[(w.head+i)%len(w.ring)].entry
+	}
+	return entries
+}
+
+// pop removes the oldest entry.
+func (w *ringWriter) pop() {
+	w.bytes -= w.ring[w.head].size
+	w.ring[w.head] = ringItem{}
+	w.head = (w.head + 1) % len(w.ring)
+	w.count--
+}
+
+// grow enlarges the ring if only the size is limited.
+func (w *ringWriter) grow() {
+	ring := make([]ringItem, 2*len(w.ring)+16)
+	for i := 0; i < w.count; i++ {
+		ring[i] = w.ring[(w.head+i)%len(w.ring)]
+	}
+	w.ring = ring
+	w.head = 0
+}
+
+// EOF
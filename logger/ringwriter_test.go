@@ -0,0 +1,98 @@
+// Tideland Go Trace - Logger - Unit Tests
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/trace/logger"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestRingWriterEntries tests keeping the last entries by number.
+func TestRingWriterEntries(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	rw := logger.NewRingWriter(logger.RingConfig{MaxEntries: 3})
+	l := logger.New(rw)
+
+	l.Info("one")
+	l.Info("two")
+	assert.Equal(messages(rw.Entries()), []string{"one", "two"})
+	l.Info("three")
+	l.Info("four")
+	l.Info("five")
+	assert.Equal(messages(rw.Entries()), []string{"three", "four", "five"})
+
+	rw.Reset()
+	assert.Length(rw.Entries(), 0)
+	l.Info("six")
+	assert.Equal(messages(rw.Entries()), []string{"six"})
+}
+
+// TestRingWriterBytes tests keeping the last entries by size.
+func TestRingWriterBytes(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	rw := logger.NewRingWriter(logger.RingConfig{MaxBytes: 20})
+	l := logger.New(rw)
+
+	for i := 0; i < 100; i++ {
+		l.Infof("entry %03d", i)
+	}
+	// Each text has 9 bytes.
+	assert.Equal(messages(rw.Entries()), []string{"entry 098", "entry 099"})
+	l.Info("far too long to be kept")
+	assert.Length(rw.Entries(), 2)
+	l.Info("short")
+	assert.Equal(messages(rw.Entries()), []string{"entry 099", "short"})
+}
+
+// TestRingWriterFollow tests following the written entries.
+func TestRingWriterFollow(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	rw := logger.NewRingWriter(logger.RingConfig{})
+	l := logger.New(rw)
+
+	l.Info("one")
+	entries, entryc, stop := rw.Follow(1)
+	assert.Equal(messages(entries), []string{"one"})
+	l.Info("two")
+	l.Info("three")
+	entry := <-entryc
+	assert.Equal(entry.Message, "two")
+	// Third has been dropped for the full channel.
+	assert.Length(entryc, 0)
+
+	stop()
+	stop()
+	l.Info("four")
+	_, ok := <-entryc
+	assert.False(ok)
+}
+
+//--------------------
+// HELPERS
+//--------------------
+
+// messages returns the messages of the entries.
+func messages(entries []logger.Entry) []string {
+	msgs := make([]string, len(entries))
+	for i, entry := range entries {
+		msgs[i] = entry.Message
+	}
+	return msgs
+}
+
+// EOF
@@ -0,0 +1,380 @@
+// Tideland Go Trace - Logger - Sampling
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger // import "tideland.dev/go/trace/logger"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"tideland.dev/go/trace/failure"
+)
+
+//--------------------
+// SAMPLER
+//--------------------
+
+// SamplingConfig contains the configuration of the rate limiting of
+// similar entries. Entries are similar if they have the same level,
+// call site, and message template. The template is the format of
+// formatted entries and the message of structured ones, so arguments
+// and fields may differ.
+type SamplingConfig struct {
+	// First is the number of similar entries written per interval.
+	First int
+
+	// Interval is the duration of one sampling window.
+	Interval time.Duration
+
+	// Now returns the current time, by default time.Now. It allows
+	// to control the intervals, e.g. in tests.
+	Now func() time.Time
+}
+
+// sampleState contains the counters of one kind of similar entries.
+type sampleState struct {
+	start      time.Time
+	count      int
+	suppressed int
+	entry      Entry
+}
+
+// summary returns the entry summarizing the suppressed ones.
+func (ss *sampleState) summary(now time.Time) Entry {
+	return Entry{
+		Time:     now,
+		Level:    ss.entry.Level,
+		Location: ss.entry.Location,
+		Message:  fmt.Sprintf("suppressed %d similar entries: %s", ss.suppressed, ss.entry.Message),
+	}
+}
+
+// sampler counts similar entries per interval.
+type sampler struct {
+	mu      sync.Mutex
+	cfg     SamplingConfig
+	states  map[string]*sampleState
+	expired time.Time
+}
+
+// newSampler creates a sampler with a valid configuration.
+func newSampler(cfg SamplingConfig) *sampler {
+	if cfg.First < 1 {
+		cfg.First = 1
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &sampler{
+		cfg:     cfg,
+		states:  make(map[string]*sampleState),
+		expired: cfg.Now(),
+	}
+}
+
+// sampleKey returns the key of similar entries. Entries without call
+// site, e.g. written directly to a writer, are compared by location.
+func sampleKey(entry Entry) string {
+	site := entry.Location.ID
+	if entry.pc != 0 {
+		site = strconv.FormatUint(uint64(entry.pc), 16)
+	}
+	template := entry.template
+	if template == "" {
+		template = entry.Message
+	}
+	return fmt.Sprintf("%d|%s|%s", entry.Level, site, template)
+}
+
+// sample admits the entry at the current time. Additionally it returns
+// the summaries of suppressed entries of the former interval and of all
+// expired ones.
+func (s *sampler) sample(entry Entry) (bool, []Entry) {
+	now := s.cfg.Now()
+	ok, summary := s.admit(entry, now)
+	var summaries []Entry
+	if summary != nil {
+		summaries = append(summaries, *summary)
+	}
+	if s.due(now) {
+		summaries = append(summaries, s.expire(now)...)
+	}
+	return ok, summaries
+}
+
+// admit checks if the entry may be written. If it starts a new interval
+// and entries have been suppressed in the former one a summary is
+// returned too.
+func (s *sampler) admit(entry Entry, now time.Time) (bool, *Entry) {
+	key := sampleKey(entry)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ss, ok := s.states[key]
+	if !ok {
+		s.states[key] = &sampleState{
+			start: now,
+			count: 1,
+			entry: entry,
+		}
+		return true, nil
+	}
+	var summary *Entry
+	if now.Sub(ss.start) >= s.cfg.Interval {
+		if ss.suppressed > 0 {
+			se := ss.summary(now)
+			summary = &se
+		}
+		ss.start = now
+		ss.count = 0
+		ss.suppressed = 0
+	}
+	ss.count++
+	if ss.count > s.cfg.First {
+		ss.suppressed++
+		return false, summary
+	}
+	return true, summary
+}
+
+// expire returns the summaries of all expired intervals and
+// removes their states.
+func (s *sampler) expire(now time.Time) []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expired = now
+	var summaries []Entry
+	for key, ss := range s.states {
+		if now.Sub(ss.start) < s.cfg.Interval {
+			continue
+		}
+		if ss.suppressed > 0 {
+			summaries = append(summaries, ss.summary(now))
+		}
+		delete(s.states, key)
+	}
+	return summaries
+}
+
+// tick passes the summaries of the expired intervals to the emit
+// function once per interval until the stop channel is closed.
+func (s *sampler) tick(stopc <-chan struct{}, emit func(summaries []Entry)) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopc:
+			return
+		case <-ticker.C:
+			if summaries := s.expire(s.cfg.Now()); len(summaries) > 0 {
+				emit(summaries)
+			}
+		}
+	}
+}
+
+// due checks if the last expiration is at least one interval ago.
+func (s *sampler) due(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return now.Sub(s.expired) >= s.cfg.Interval
+}
+
+//--------------------
+// SAMPLING WRITER
+//--------------------
+
+// SamplingWriter is a writer limiting the number of similar entries.
+type SamplingWriter interface {
+	EntryWriter
+	Flusher
+
+	// Close stops the writer, writes the pending summaries, and
+	// closes the wrapped writer if it implements io.Closer.
+	Close() error
+}
+
+// samplingWriter implements SamplingWriter.
+type samplingWriter struct {
+	mu      sync.Mutex
+	out     EntryWriter
+	sampler *sampler
+	stopc   chan struct{}
+	donec   chan struct{}
+	once    sync.Once
+}
+
+// NewSamplingWriter wraps the passed writer. Only the first entries
+// of similar ones are written per interval. The number of suppressed
+// entries is written as summary periodically.
+func NewSamplingWriter(out Writer, cfg SamplingConfig) SamplingWriter {
+	w := &samplingWriter{
+		out:     AdaptWriter(out),
+		sampler: newSampler(cfg),
+		stopc:   make(chan struct{}),
+		donec:   make(chan struct{}),
+	}
+	go w.backend()
+	return w
+}
+
+// Write implements Writer.
+func (w *samplingWriter) Write(level LogLevel, msg string) error {
+	return w.WriteEntry(Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: msg,
+	})
+}
+
+// WriteEntry implements EntryWriter.
+func (w *samplingWriter) WriteEntry(entry Entry) error {
+	ok, summary := w.sampler.admit(entry, w.sampler.cfg.Now())
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var err error
+	if summary != nil {
+		err = w.out.WriteEntry(*summary)
+	}
+	if ok {
+		err = failure.First(err, w.out.WriteEntry(entry))
+	}
+	return err
+}
+
+// unwrap implements wrapper.
+func (w *samplingWriter) unwrap() Writer {
+	return unadaptWriter(w.out)
+}
+
+// Close implements SamplingWriter.
+func (w *samplingWriter) Close() error {
+	w.once.Do(func() {
+		close(w.stopc)
+	})
+	<-w.donec
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var errs []error
+	for _, summary := range w.sampler.expire(w.sampler.cfg.Now().Add(w.sampler.cfg.Interval)) {
+		errs = append(errs, w.out.WriteEntry(summary))
+	}
+	_, err := closeWriter(w.out)
+	errs = append(errs, err)
+	return failure.Collect(errs...)
+}
+
+// Flush implements Flusher. It flushes the wrapped writer.
+func (w *samplingWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return flushWriter(w.out)
+}
+
+// backend periodically writes the summaries of expired intervals.
+func (w *samplingWriter) backend() {
+	defer close(w.donec)
+	w.sampler.tick(w.stopc, func(summaries []Entry) {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		for _, summary := range summaries {
+			_ = w.out.WriteEntry(summary)
+		}
+	})
+}
+
+//--------------------
+// SAMPLING FILTER
+//--------------------
+
+// NewSamplingFilter returns a filter function letting only the first
+// entries of similar ones pass per interval. As the filter only gets the
+// level and the text, entries are similar if both are equal. To compare
+// the message templates and call sites use SetSampling() instead. The
+// summaries of suppressed entries are logged with the passed logger, or
+// the default one if nil, when a new interval of a similar entry starts
+// or while filtering any entry after the interval.
+func NewSamplingFilter(l *Logger, cfg SamplingConfig) FilterFunc {
+	if l == nil {
+		l = std
+	}
+	s := newSampler(cfg)
+	return func(level LogLevel, msg string) bool {
+		ok, summaries := s.sample(Entry{
+			Time:    s.cfg.Now(),
+			Level:   level,
+			Message: msg,
+		})
+		for _, summary := range summaries {
+			l.backend.write(summary)
+		}
+		return ok
+	}
+}
+
+//--------------------
+// LOGGER SAMPLING
+//--------------------
+
+// SetSampling lets the logger write only the first entries of similar
+// ones per interval. Entries are similar if level, call site, and message
+// template are equal, e.g. all entries of Errorf("failure %d", i) inside
+// a loop. The summaries of suppressed entries are written like all other
+// entries once per interval, or earlier when a new interval of a similar
+// entry starts.
+func (l *Logger) SetSampling(cfg SamplingConfig) {
+	l.backend.mu.Lock()
+	defer l.backend.mu.Unlock()
+	l.backend.setSampler(newSampler(cfg))
+}
+
+// UnsetSampling stops the sampling of the logger. Pending summaries
+// are dropped.
+func (l *Logger) UnsetSampling() {
+	l.backend.mu.Lock()
+	defer l.backend.mu.Unlock()
+	l.backend.setSampler(nil)
+}
+
+// setSampler replaces the sampler of the backend and starts the
+// periodic writing of its summaries. The lock has to be held.
+func (lb *loggerBackend) setSampler(s *sampler) {
+	if lb.samplerStopc != nil {
+		close(lb.samplerStopc)
+		lb.samplerStopc = nil
+	}
+	lb.sampler = s
+	if s == nil {
+		return
+	}
+	lb.samplerStopc = make(chan struct{})
+	go s.tick(lb.samplerStopc, func(summaries []Entry) {
+		for _, summary := range summaries {
+			lb.deliver(summary)
+		}
+	})
+}
+
+// SetSampling lets the default logger sample similar entries.
+func SetSampling(cfg SamplingConfig) {
+	std.SetSampling(cfg)
+}
+
+// UnsetSampling stops the sampling of the default logger.
+func UnsetSampling() {
+	std.UnsetSampling()
+}
+
+// EOF
@@ -0,0 +1,212 @@
+// Tideland Go Trace - Logger - Unit Tests
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/trace/logger"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestSamplingWriter tests the limiting of similar entries by a writer.
+func TestSamplingWriter(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	clock := newTestClock()
+	tw := logger.NewTestWriter()
+	sw := logger.NewSamplingWriter(tw, logger.SamplingConfig{
+		First:    3,
+		Interval: time.Hour,
+		Now:      clock.Now,
+	})
+	l := logger.New(sw)
+
+	for i := 0; i <= 100; i++ {
+		if i == 100 {
+			l.Error("other")
+			assert.Length(tw, 7)
+			// New interval starts for the error.
+			clock.Advance(time.Hour)
+		}
+		l.Error("hot loop", "i", i)
+		if i < 100 {
+			l.Warning("hot loop", "i", i)
+		}
+	}
+	es := tw.Entries()
+	assert.Length(es, 9)
+	assert.Contains("[ERROR] suppressed 97 similar entries: hot loop", es[7])
+	assert.Contains("[ERROR] hot loop i=100", es[8])
+
+	// Closing writes the pending summary.
+	assert.Nil(sw.Close())
+	es = tw.Entries()
+	assert.Length(es, 10)
+	assert.Contains("[WARNING] suppressed 97 similar entries: hot loop", es[9])
+}
+
+// TestSampling tests the limiting of similar entries by the logger
+// using message templates and call sites.
+func TestSampling(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	clock := newTestClock()
+	tw := logger.NewTestWriter()
+	l := logger.New(tw)
+	l.SetSampling(logger.SamplingConfig{
+		First:    2,
+		Interval: time.Hour,
+		Now:      clock.Now,
+	})
+	l.SetRedaction(logger.DefaultRedactionConfig())
+	var hooked []string
+	l.AddHook("collect", logger.AllLevels, func(entry logger.Entry) {
+		hooked = append(hooked, entry.Message)
+	})
+
+	for i := 0; i < 10; i++ {
+		l.Errorf("failure %d with Bearer abc%d", i, i)
+	}
+	assert.Length(tw, 2)
+
+	// Same message but different call sites.
+	for i := 0; i < 2; i++ {
+		l.Error("same")
+		l.Error("same")
+	}
+	assert.Length(tw, 6)
+
+	// Summaries pass redaction and hooks.
+	clock.Advance(time.Hour)
+	l.Info("next")
+	es := tw.Entries()
+	assert.Length(es, 8)
+	assert.Contains("[ERROR] suppressed 8 similar entries: failure 0 with [REDACTED]", es[6])
+	assert.Contains("[INFO] next", es[7])
+	assert.Length(hooked, 8)
+	assert.Equal(hooked[6], "suppressed 8 similar entries: failure 0 with [REDACTED]")
+
+	l.UnsetSampling()
+	for i := 0; i < 3; i++ {
+		l.Errorf("failure %d", i)
+	}
+	assert.Length(tw, 11)
+}
+
+// TestSamplingTicker tests the writing of the summaries once per
+// interval without further entries.
+func TestSamplingTicker(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	cw := logger.NewCaptureWriter()
+	l := logger.New(cw)
+	l.SetSampling(logger.SamplingConfig{
+		First:    1,
+		Interval: 20 * time.Millisecond,
+	})
+	defer l.UnsetSampling()
+
+	for i := 0; i < 5; i++ {
+		l.Errorf("failure %d", i)
+	}
+	assert.Equal(cw.Len(), 1)
+	_, ok := cw.WaitFor(logger.LevelError, "suppressed 4 similar entries: failure 0", time.Second)
+	assert.True(ok)
+	assert.Equal(cw.Len(), 2)
+}
+
+// TestSamplingWriterTicker tests that the periodic summaries and the
+// entries don't write concurrently to the wrapped writer.
+func TestSamplingWriterTicker(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	out := &simpleWriter{}
+	sw := logger.NewSamplingWriter(out, logger.SamplingConfig{
+		First:    1,
+		Interval: time.Millisecond,
+	})
+
+	for i := 0; i < 200; i++ {
+		assert.Nil(sw.Write(logger.LevelInfo, "tick"))
+		if i%20 == 0 {
+			time.Sleep(2 * time.Millisecond)
+		}
+	}
+	assert.Nil(sw.Close())
+	assert.True(len(out.msgs) >= 2)
+}
+
+// TestSamplingFilter tests the limiting of similar entries by a filter.
+func TestSamplingFilter(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	clock := newTestClock()
+	tw := logger.NewTestWriter()
+	l := logger.New(tw)
+	l.SetFilter(logger.NewSamplingFilter(l, logger.SamplingConfig{
+		First:    2,
+		Interval: time.Hour,
+		Now:      clock.Now,
+	}))
+	var hooked int
+	l.AddHook("count", logger.AllLevels, func(entry logger.Entry) {
+		hooked++
+	})
+
+	for i := 0; i < 10; i++ {
+		l.Errorf("failure")
+	}
+	assert.Length(tw, 2)
+
+	clock.Advance(time.Hour)
+	l.Errorf("failure")
+	es := tw.Entries()
+	assert.Length(es, 4)
+	assert.Contains("suppressed 8 similar entries: failure", es[2])
+	assert.Contains("failure", es[3])
+	assert.Equal(hooked, 4)
+}
+
+//--------------------
+// HELPERS
+//--------------------
+
+// testClock is a manually advanced clock.
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// newTestClock creates a clock starting at a fixed time.
+func newTestClock() *testClock {
+	return &testClock{
+		now: time.Date(2020, time.May, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+// Now returns the current time of the clock.
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward.
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// EOF
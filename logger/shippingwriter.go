@@ -0,0 +1,611 @@
+// Tideland Go Trace - Logger - Shipping Writer
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger // import "tideland.dev/go/trace/logger"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"tideland.dev/go/trace/failure"
+)
+
+//--------------------
+// SHIPPING SETTINGS
+//--------------------
+
+// Defaults of the shipping configuration.
+const (
+	defaultShippingBatchSize    = 100
+	defaultShippingInterval     = time.Second
+	defaultShippingRetries      = 3
+	defaultShippingBackoff      = 500 * time.Millisecond
+	defaultShippingMaxBackoff   = 30 * time.Second
+	defaultShippingMaxPending   = 10000
+	defaultShippingMaxSpooled   = 100
+	defaultShippingIndex        = "logs"
+	shippingSpoolFileExtension  = ".batch"
+	shippingLokiLevelLabel      = "level"
+	shippingLokiUnknownLevel    = "unknown"
+	shippingContentTypeNDJSON   = "application/x-ndjson"
+	shippingContentTypeJSON     = "application/json"
+	shippingContentEncodingGzip = "gzip"
+)
+
+// lokiLabelName matches valid Loki label names.
+var lokiLabelName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// ShippingFormat describes the format of the shipped batches.
+type ShippingFormat int
+
+// Supported shipping formats.
+const (
+	// ShipJSON posts a JSON array of entries.
+	ShipJSON ShippingFormat = iota
+
+	// ShipLoki posts to the Loki push API. The entries are grouped
+	// into streams by the labels and the level.
+	ShipLoki
+
+	// ShipElasticsearch posts to the Elasticsearch bulk API.
+	ShipElasticsearch
+)
+
+// ShippingConfig contains the configuration of a shipping writer.
+type ShippingConfig struct {
+	// URL of the ingestion endpoint, e.g. "http://loki:3100/loki/api/v1/push".
+	URL string
+
+	// Format of the shipped batches.
+	Format ShippingFormat
+
+	// Labels are passed as stream labels to Loki and as additional
+	// fields of the entries for the other formats.
+	Labels map[string]string
+
+	// Index is the Elasticsearch index, by default "logs".
+	Index string
+
+	// Header contains additional request headers, e.g. for authorization.
+	Header http.Header
+
+	// Client is used for the requests, by default http.DefaultClient.
+	Client *http.Client
+
+	// BatchSize is the number of entries shipped together, by default 100.
+	BatchSize int
+
+	// Interval in which incomplete batches are shipped, by default 1s.
+	Interval time.Duration
+
+	// Gzip compresses the request bodies.
+	Gzip bool
+
+	// Retries is the number of retries of failed requests, by default 3.
+	// A negative number disables retries.
+	Retries int
+
+	// Backoff is the time before the first retry, by default 500ms. It
+	// is doubled for each further retry up to 30s.
+	Backoff time.Duration
+
+	// MaxPending limits the number of entries waiting for shipping, by
+	// default 10000. If it is reached the oldest are dropped.
+	MaxPending int
+
+	// SpoolDir is the directory failed batches are stored in. They are
+	// shipped again after the next successful request. If empty failed
+	// batches are dropped.
+	SpoolDir string
+
+	// MaxSpooled limits the number of spooled batches, by default 100.
+	// If it is reached the oldest are removed.
+	MaxSpooled int
+}
+
+// ShippingStats contains the counters of a shipping writer.
+type ShippingStats struct {
+	Pending int
+	Shipped uint64
+	Dropped uint64
+	Spooled uint64
+}
+
+//--------------------
+// SHIPPING WRITER
+//--------------------
+
+// ShippingWriter is a writer shipping batches of entries to an HTTP
+// ingestion endpoint.
+type ShippingWriter interface {
+	EntryWriter
+	Flusher
+
+	// Stats returns the current counters.
+	Stats() ShippingStats
+
+	// Close ships the pending entries and stops the writer. If shipping
+	// fails the remaining entries are spooled, or dropped without spool
+	// directory. The collected errors are returned.
+	Close() error
+}
+
+// shippingWriter implements ShippingWriter.
+type shippingWriter struct {
+	mu       sync.Mutex
+	cfg      ShippingConfig
+	labels   Fields
+	pending  []Entry
+	stats    ShippingStats
+	err      error
+	closed   bool
+	spoolSeq int
+	batchc   chan struct{}
+	flushc   chan chan error
+	closec   chan struct{}
+	donec    chan struct{}
+}
+
+// NewShippingWriter creates a writer collecting the entries in batches
+// and posting them in the background to an HTTP ingestion endpoint like
+// the Loki push API or the Elasticsearch bulk API.
+func NewShippingWriter(cfg ShippingConfig) (ShippingWriter, error) {
+	if cfg.URL == "" {
+		return nil, failure.New("missing shipping URL")
+	}
+	if cfg.Format < ShipJSON || cfg.Format > ShipElasticsearch {
+		return nil, failure.New("invalid shipping format %d", cfg.Format)
+	}
+	names := make([]string, 0, len(cfg.Labels))
+	for name := range cfg.Labels {
+		if cfg.Format == ShipLoki && (!lokiLabelName.MatchString(name) || name == shippingLokiLevelLabel) {
+			return nil, failure.New("invalid shipping label %q", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if cfg.SpoolDir != "" {
+		if err := os.MkdirAll(cfg.SpoolDir, 0755); err != nil {
+			return nil, failure.Annotate(err, "cannot create spool directory %q", cfg.SpoolDir)
+		}
+	}
+	if cfg.Index == "" {
+		cfg.Index = defaultShippingIndex
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultShippingBatchSize
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultShippingInterval
+	}
+	if cfg.Retries < 0 {
+		cfg.Retries = 0
+	} else if cfg.Retries == 0 {
+		cfg.Retries = defaultShippingRetries
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = defaultShippingBackoff
+	}
+	if cfg.MaxPending <= 0 {
+		cfg.MaxPending = defaultShippingMaxPending
+	}
+	if cfg.MaxSpooled <= 0 {
+		cfg.MaxSpooled = defaultShippingMaxSpooled
+	}
+	w := &shippingWriter{
+		cfg:    cfg,
+		batchc: make(chan struct{}, 1),
+		flushc: make(chan chan error),
+		closec: make(chan struct{}),
+		donec:  make(chan struct{}),
+	}
+	for _, name := range names {
+		w.labels = append(w.labels, F(name, cfg.Labels[name]))
+	}
+	go w.backend()
+	return w, nil
+}
+
+// Write implements Writer.
+func (w *shippingWriter) Write(level LogLevel, msg string) error {
+	return w.WriteEntry(Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: msg,
+	})
+}
+
+// WriteEntry implements EntryWriter.
+func (w *shippingWriter) WriteEntry(entry Entry) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return failure.New("shipping writer is closed")
+	}
+	if len(w.pending) >= w.cfg.MaxPending {
+		w.pending = w.pending[1:]
+		w.stats.Dropped++
+	}
+	w.pending = append(w.pending, entry)
+	if len(w.pending) >= w.cfg.BatchSize {
+		select {
+		case w.batchc <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Flush implements Flusher. It ships all pending entries and returns
+// the last error.
+func (w *shippingWriter) Flush() error {
+	errc := make(chan error, 1)
+	select {
+	case w.flushc <- errc:
+		return <-errc
+	case <-w.donec:
+		return nil
+	}
+}
+
+// Stats implements ShippingWriter.
+func (w *shippingWriter) Stats() ShippingStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	stats := w.stats
+	stats.Pending = len(w.pending)
+	return stats
+}
+
+// Close implements ShippingWriter.
+func (w *shippingWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.mu.Unlock()
+	close(w.closec)
+	<-w.donec
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// backend ships the batches in the background.
+func (w *shippingWriter) backend() {
+	defer close(w.donec)
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.shipPending()
+		case <-w.batchc:
+			w.shipPending()
+		case errc := <-w.flushc:
+			w.shipPending()
+			w.mu.Lock()
+			errc <- w.err
+			w.err = nil
+			w.mu.Unlock()
+		case <-w.closec:
+			w.shipPending()
+			w.spoolRemaining()
+			return
+		}
+	}
+}
+
+// shipPending ships the pending entries in batches. After successful
+// requests the spooled batches are shipped again.
+func (w *shippingWriter) shipPending() {
+	for {
+		w.mu.Lock()
+		n := len(w.pending)
+		if n > w.cfg.BatchSize {
+			n = w.cfg.BatchSize
+		}
+		batch := make([]Entry, n)
+		copy(batch, w.pending)
+		w.pending = w.pending[n:]
+		w.mu.Unlock()
+		if n == 0 {
+			w.shipSpooled()
+			return
+		}
+		body := w.encode(batch)
+		retry, err := w.send(body)
+		spooled := false
+		if err != nil && retry && w.cfg.SpoolDir != "" {
+			if serr := w.spool(body); serr != nil {
+				err = failure.Collect(err, serr)
+			} else {
+				spooled = true
+			}
+		}
+		w.mu.Lock()
+		switch {
+		case err == nil:
+			w.stats.Shipped += uint64(n)
+		case spooled:
+			w.err = err
+			w.stats.Spooled += uint64(n)
+		default:
+			w.err = err
+			w.stats.Dropped += uint64(n)
+		}
+		w.mu.Unlock()
+		if err != nil {
+			// Stop on errors, next try with the next batch.
+			return
+		}
+	}
+}
+
+// spoolRemaining spools the entries still pending when closing after
+// a failed shipping in batches, or drops them without spool directory.
+// Errors are collected.
+func (w *shippingWriter) spoolRemaining() {
+	w.mu.Lock()
+	pending := w.pending
+	w.pending = nil
+	w.mu.Unlock()
+	for len(pending) > 0 {
+		n := len(pending)
+		if n > w.cfg.BatchSize {
+			n = w.cfg.BatchSize
+		}
+		batch := pending[:n]
+		pending = pending[n:]
+		var err error
+		if w.cfg.SpoolDir != "" {
+			err = w.spool(w.encode(batch))
+		}
+		w.mu.Lock()
+		switch {
+		case w.cfg.SpoolDir == "":
+			w.stats.Dropped += uint64(n)
+		case err != nil:
+			w.err = failure.Collect(w.err, err)
+			w.stats.Dropped += uint64(n)
+		default:
+			w.stats.Spooled += uint64(n)
+		}
+		w.mu.Unlock()
+	}
+}
+
+// send posts the body with retries. It returns if the failed body
+// can be shipped again later.
+func (w *shippingWriter) send(body []byte) (bool, error) {
+	backoff := w.cfg.Backoff
+	var err error
+	for try := 0; try <= w.cfg.Retries; try++ {
+		if try > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+			if backoff > defaultShippingMaxBackoff {
+				backoff = defaultShippingMaxBackoff
+			}
+		}
+		var retry bool
+		retry, err = w.post(body)
+		if err == nil || !retry {
+			return false, err
+		}
+	}
+	return true, err
+}
+
+// post performs one request. It returns if a failed request can be retried.
+func (w *shippingWriter) post(body []byte) (bool, error) {
+	var reader io.Reader = bytes.NewReader(body)
+	if w.cfg.Gzip {
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		gw.Write(body)
+		gw.Close()
+		reader = &buf
+	}
+	req, err := http.NewRequest(http.MethodPost, w.cfg.URL, reader)
+	if err != nil {
+		return false, failure.Annotate(err, "cannot create shipping request")
+	}
+	for key, values := range w.cfg.Header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	if w.cfg.Format == ShipElasticsearch {
+		req.Header.Set("Content-Type", shippingContentTypeNDJSON)
+	} else {
+		req.Header.Set("Content-Type", shippingContentTypeJSON)
+	}
+	if w.cfg.Gzip {
+		req.Header.Set("Content-Encoding", shippingContentEncodingGzip)
+	}
+	resp, err := w.cfg.Client.Do(req)
+	if err != nil {
+		return true, failure.Annotate(err, "cannot ship entries")
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, failure.New("cannot ship entries: %s", resp.Status)
+	default:
+		return false, failure.New("entries rejected: %s", resp.Status)
+	}
+}
+
+//--------------------
+// SPOOLING
+//--------------------
+
+// spool stores the body of a failed batch in the spool directory.
+func (w *shippingWriter) spool(body []byte) error {
+	w.spoolSeq++
+	name := fmt.Sprintf("%020d-%06d%s", time.Now().UnixNano(), w.spoolSeq, shippingSpoolFileExtension)
+	path := filepath.Join(w.cfg.SpoolDir, name)
+	if err := os.WriteFile(path, body, 0644); err != nil {
+		return failure.Annotate(err, "cannot spool batch")
+	}
+	spooled := w.spooled()
+	for len(spooled) > w.cfg.MaxSpooled {
+		os.Remove(spooled[0])
+		spooled = spooled[1:]
+	}
+	return nil
+}
+
+// spooled returns the paths of the spooled batches, oldest first.
+func (w *shippingWriter) spooled() []string {
+	if w.cfg.SpoolDir == "" {
+		return nil
+	}
+	paths, _ := filepath.Glob(filepath.Join(w.cfg.SpoolDir, "*"+shippingSpoolFileExtension))
+	sort.Strings(paths)
+	return paths
+}
+
+// shipSpooled ships the spooled batches until one fails.
+func (w *shippingWriter) shipSpooled() {
+	for _, path := range w.spooled() {
+		body, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		retry, err := w.send(body)
+		if err != nil && retry {
+			w.mu.Lock()
+			w.err = err
+			w.mu.Unlock()
+			return
+		}
+		// Shipped or rejected.
+		os.Remove(path)
+	}
+}
+
+//--------------------
+// ENCODING
+//--------------------
+
+// encode renders the batch in the configured format.
+func (w *shippingWriter) encode(batch []Entry) []byte {
+	var buf bytes.Buffer
+	switch w.cfg.Format {
+	case ShipLoki:
+		w.encodeLoki(&buf, batch)
+	case ShipElasticsearch:
+		for _, entry := range batch {
+			buf.WriteString(`{"index":{"_index":`)
+			writeJSONValue(&buf, w.cfg.Index)
+			buf.WriteString("}}\n")
+			buf.Write(marshalEntry(w.labeled(entry)))
+			buf.WriteByte('\n')
+		}
+	default:
+		buf.WriteByte('[')
+		for i, entry := range batch {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.Write(marshalEntry(w.labeled(entry)))
+		}
+		buf.WriteByte(']')
+	}
+	return buf.Bytes()
+}
+
+// encodeLoki renders the batch as Loki push request with one stream
+// per level. Entries with invalid levels get the level "unknown".
+func (w *shippingWriter) encodeLoki(buf *bytes.Buffer, batch []Entry) {
+	streams := make(map[string][]Entry)
+	for _, entry := range batch {
+		level := shippingLokiUnknownLevel
+		if _, ok := levelText[entry.Level]; ok {
+			level = strings.ToLower(levelToText(entry.Level))
+		}
+		streams[level] = append(streams[level], entry)
+	}
+	var levels []string
+	for level := LevelDebug; level <= LevelFatal; level++ {
+		levels = append(levels, strings.ToLower(levelToText(level)))
+	}
+	levels = append(levels, shippingLokiUnknownLevel)
+	buf.WriteString(`{"streams":[`)
+	first := true
+	for _, level := range levels {
+		entries, ok := streams[level]
+		if !ok {
+			continue
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		buf.WriteString(`{"stream":{`)
+		for _, label := range w.labels {
+			writeJSONValue(buf, label.Key)
+			buf.WriteByte(':')
+			writeJSONValue(buf, label.Value)
+			buf.WriteByte(',')
+		}
+		writeJSONValue(buf, shippingLokiLevelLabel)
+		buf.WriteByte(':')
+		writeJSONValue(buf, level)
+		buf.WriteString(`},"values":[`)
+		for i, entry := range entries {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteByte('[')
+			writeJSONValue(buf, strconv.FormatInt(entry.Time.UnixNano(), 10))
+			buf.WriteByte(',')
+			writeJSONValue(buf, string(marshalEntry(entry)))
+			buf.WriteByte(']')
+		}
+		buf.WriteString(`]}`)
+	}
+	buf.WriteString(`]}`)
+}
+
+// labeled returns the entry with the labels added as fields.
+func (w *shippingWriter) labeled(entry Entry) Entry {
+	if len(w.labels) == 0 {
+		return entry
+	}
+	fields := make(Fields, 0, len(w.labels)+len(entry.Fields))
+	fields = append(fields, w.labels...)
+	entry.Fields = append(fields, entry.Fields...)
+	return entry
+}
+
+// EOF
@@ -0,0 +1,336 @@
+// Tideland Go Trace - Logger - Unit Tests
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/trace/logger"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestShippingJSON tests shipping compressed batches as JSON arrays.
+func TestShippingJSON(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	is := newIngestServer()
+	defer is.Close()
+
+	sw, err := logger.NewShippingWriter(logger.ShippingConfig{
+		URL:       is.URL,
+		Labels:    map[string]string{"app": "myapp"},
+		Header:    http.Header{"Authorization": {"Bearer xyz"}},
+		BatchSize: 2,
+		Interval:  time.Hour,
+		Gzip:      true,
+	})
+	assert.Nil(err)
+	l := logger.New(sw)
+
+	l.Info("one", "n", 1)
+	l.Warning("two")
+	l.Error("three")
+	assert.Nil(sw.Flush())
+	bodies := is.Bodies()
+	assert.Length(bodies, 2)
+	assert.Equal(is.Header("Authorization"), "Bearer xyz")
+	assert.Equal(is.Header("Content-Encoding"), "gzip")
+
+	var entries []map[string]interface{}
+	assert.Nil(json.Unmarshal([]byte(bodies[0]), &entries))
+	assert.Length(entries, 2)
+	assert.Equal(entries[0]["message"], "one")
+	assert.Equal(entries[0]["fields"], map[string]interface{}{"app": "myapp", "n": 1.0})
+	assert.Contains(`"message":"three"`, bodies[1])
+	assert.Equal(sw.Stats().Shipped, uint64(3))
+
+	assert.Nil(sw.Close())
+	assert.ErrorContains(sw.Write(logger.LevelInfo, "closed"), "shipping writer is closed")
+}
+
+// TestShippingLoki tests shipping to the Loki push API.
+func TestShippingLoki(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	is := newIngestServer()
+	defer is.Close()
+
+	sw, err := logger.NewShippingWriter(logger.ShippingConfig{
+		URL:    is.URL,
+		Format: logger.ShipLoki,
+		Labels: map[string]string{"app": "myapp", "env": "test"},
+	})
+	assert.Nil(err)
+	l := logger.New(sw)
+
+	l.Info("one")
+	l.Error("two")
+	l.Info("three")
+	assert.Nil(sw.WriteEntry(logger.Entry{Level: logger.LogLevel(42), Message: "four"}))
+	assert.Nil(sw.Close())
+
+	var push struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][]string        `json:"values"`
+		} `json:"streams"`
+	}
+	bodies := is.Bodies()
+	assert.Length(bodies, 1)
+	assert.Nil(json.Unmarshal([]byte(bodies[0]), &push))
+	assert.Length(push.Streams, 3)
+	assert.Equal(push.Streams[0].Stream, map[string]string{"app": "myapp", "env": "test", "level": "info"})
+	assert.Length(push.Streams[0].Values, 2)
+	assert.Contains(`"message":"three"`, push.Streams[0].Values[1][1])
+	assert.Equal(push.Streams[1].Stream["level"], "error")
+	assert.Equal(push.Streams[2].Stream["level"], "unknown")
+	assert.Contains(`"message":"four"`, push.Streams[2].Values[0][1])
+}
+
+// TestShippingElasticsearch tests shipping to the Elasticsearch bulk API.
+func TestShippingElasticsearch(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	is := newIngestServer()
+	defer is.Close()
+
+	sw, err := logger.NewShippingWriter(logger.ShippingConfig{
+		URL:    is.URL,
+		Format: logger.ShipElasticsearch,
+		Index:  "app-logs",
+	})
+	assert.Nil(err)
+	l := logger.New(sw)
+
+	l.Info("one")
+	l.Warning("two")
+	assert.Nil(sw.Close())
+
+	assert.Equal(is.Header("Content-Type"), "application/x-ndjson")
+	lines := strings.Split(strings.TrimSpace(is.Bodies()[0]), "\n")
+	assert.Length(lines, 4)
+	assert.Equal(lines[0], `{"index":{"_index":"app-logs"}}`)
+	assert.Contains(`"level":"WARNING","message":"two"`, lines[3])
+}
+
+// TestShippingRetry tests retrying failed requests.
+func TestShippingRetry(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	is := newIngestServer()
+	defer is.Close()
+	is.Fail(2, http.StatusServiceUnavailable)
+
+	sw, err := logger.NewShippingWriter(logger.ShippingConfig{
+		URL:     is.URL,
+		Backoff: time.Millisecond,
+	})
+	assert.Nil(err)
+	defer sw.Close()
+
+	assert.Nil(sw.Write(logger.LevelInfo, "retried"))
+	assert.Nil(sw.Flush())
+	assert.Length(is.Bodies(), 1)
+	assert.Equal(is.Requests(), 3)
+
+	// Rejected batches are not retried.
+	is.Fail(1, http.StatusBadRequest)
+	assert.Nil(sw.Write(logger.LevelInfo, "rejected"))
+	assert.ErrorContains(sw.Flush(), "entries rejected: 400 Bad Request")
+	assert.Equal(sw.Stats().Dropped, uint64(1))
+
+	// Without spool directory the transport error is returned.
+	is.Fail(4, http.StatusServiceUnavailable)
+	assert.Nil(sw.Write(logger.LevelInfo, "dropped"))
+	err = sw.Flush()
+	assert.ErrorContains(err, "cannot ship entries: 503 Service Unavailable")
+	assert.False(strings.Contains(err.Error(), "spool"))
+	assert.Equal(sw.Stats().Dropped, uint64(2))
+}
+
+// TestShippingSpool tests spooling batches during outages.
+func TestShippingSpool(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	is := newIngestServer()
+	defer is.Close()
+	spoolDir := filepath.Join(t.TempDir(), "spool")
+
+	sw, err := logger.NewShippingWriter(logger.ShippingConfig{
+		URL:      is.URL,
+		Retries:  -1,
+		SpoolDir: spoolDir,
+	})
+	assert.Nil(err)
+	defer sw.Close()
+
+	is.Fail(2, http.StatusInternalServerError)
+	assert.Nil(sw.Write(logger.LevelInfo, "one"))
+	assert.ErrorContains(sw.Flush(), "cannot ship entries: 500 Internal Server Error")
+	assert.Nil(sw.Write(logger.LevelInfo, "two"))
+	assert.NotNil(sw.Flush())
+	spooled, err := os.ReadDir(spoolDir)
+	assert.Nil(err)
+	assert.Length(spooled, 2)
+	assert.Equal(sw.Stats().Spooled, uint64(2))
+
+	// Spooled batches are shipped after the next success.
+	assert.Nil(sw.Write(logger.LevelInfo, "three"))
+	assert.Nil(sw.Flush())
+	bodies := is.Bodies()
+	assert.Length(bodies, 3)
+	assert.Contains(`"message":"three"`, bodies[0])
+	assert.Contains(`"message":"one"`, bodies[1])
+	assert.Contains(`"message":"two"`, bodies[2])
+	spooled, err = os.ReadDir(spoolDir)
+	assert.Nil(err)
+	assert.Length(spooled, 0)
+}
+
+// TestShippingClose tests closing the writer during an outage.
+func TestShippingClose(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	is := newIngestServer()
+	defer is.Close()
+
+	for _, spoolDir := range []string{"", filepath.Join(t.TempDir(), "spool")} {
+		sw, err := logger.NewShippingWriter(logger.ShippingConfig{
+			URL:       is.URL,
+			BatchSize: 2,
+			Interval:  time.Hour,
+			Retries:   -1,
+			SpoolDir:  spoolDir,
+		})
+		assert.Nil(err)
+
+		is.Fail(100, http.StatusServiceUnavailable)
+		for i := 0; i < 5; i++ {
+			assert.Nil(sw.Write(logger.LevelInfo, "outage"))
+		}
+		assert.ErrorContains(sw.Close(), "cannot ship entries: 503 Service Unavailable")
+		stats := sw.Stats()
+		assert.Equal(stats.Pending, 0)
+		assert.Equal(stats.Shipped, uint64(0))
+		assert.Equal(stats.Spooled+stats.Dropped, uint64(5))
+		if spoolDir == "" {
+			assert.Equal(stats.Dropped, uint64(5))
+		} else {
+			assert.Equal(stats.Spooled, uint64(5))
+		}
+	}
+}
+
+// TestShippingConfig tests the validation of the configuration.
+func TestShippingConfig(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+
+	_, err := logger.NewShippingWriter(logger.ShippingConfig{})
+	assert.ErrorContains(err, "missing shipping URL")
+	_, err = logger.NewShippingWriter(logger.ShippingConfig{
+		URL:    "http://localhost",
+		Format: 42,
+	})
+	assert.ErrorContains(err, "invalid shipping format 42")
+	_, err = logger.NewShippingWriter(logger.ShippingConfig{
+		URL:    "http://localhost",
+		Format: logger.ShipLoki,
+		Labels: map[string]string{"app-name": "myapp"},
+	})
+	assert.ErrorContains(err, `invalid shipping label "app-name"`)
+}
+
+//--------------------
+// HELPERS
+//--------------------
+
+// ingestServer records the bodies of the received requests.
+type ingestServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	bodies   []string
+	header   http.Header
+	requests int
+	failures int
+	status   int
+}
+
+// newIngestServer starts a new ingestion test server.
+func newIngestServer() *ingestServer {
+	is := &ingestServer{}
+	is.Server = httptest.NewServer(http.HandlerFunc(is.serve))
+	return is
+}
+
+func (is *ingestServer) serve(w http.ResponseWriter, r *http.Request) {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	is.requests++
+	is.header = r.Header
+	if is.failures > 0 {
+		is.failures--
+		w.WriteHeader(is.status)
+		return
+	}
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body = gr
+	}
+	data, _ := io.ReadAll(body)
+	is.bodies = append(is.bodies, string(data))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Fail lets the next requests fail with the status.
+func (is *ingestServer) Fail(failures, status int) {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	is.failures = failures
+	is.status = status
+}
+
+// Bodies returns the received bodies.
+func (is *ingestServer) Bodies() []string {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	return append([]string{}, is.bodies...)
+}
+
+// Header returns a header value of the last request.
+func (is *ingestServer) Header(key string) string {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	return is.header.Get(key)
+}
+
+// Requests returns the number of received requests.
+func (is *ingestServer) Requests() int {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	return is.requests
+}
+
+// EOF
@@ -0,0 +1,211 @@
+// Tideland Go Trace - Logger - Shutdown
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger // import "tideland.dev/go/trace/logger"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"tideland.dev/go/trace/failure"
+)
+
+//--------------------
+// SHUTDOWN HOOKS
+//--------------------
+
+// defaultShutdownTimeout is the time the shutdown hooks have by default.
+const defaultShutdownTimeout = 5 * time.Second
+
+// ShutdownHookFunc defines a function called in case of a fatal entry
+// before the writers are closed and the fatal exiter is called. The
+// context is done when the shutdown deadline is reached.
+type ShutdownHookFunc func(ctx context.Context) error
+
+// shutdownHook is one registered hook.
+type shutdownHook struct {
+	name string
+	hook ShutdownHookFunc
+}
+
+// shutdownHooks contains the hooks of a logger in the order of their
+// registration. It is never changed, only replaced, so it can be used
+// without holding the lock of the backend.
+type shutdownHooks []shutdownHook
+
+// with returns new hooks containing the passed one. A hook with the
+// same name is replaced at its position.
+func (shs shutdownHooks) with(sh shutdownHook) shutdownHooks {
+	nshs := make(shutdownHooks, 0, len(shs)+1)
+	replaced := false
+	for _, csh := range shs {
+		if csh.name == sh.name {
+			nshs = append(nshs, sh)
+			replaced = true
+			continue
+		}
+		nshs = append(nshs, csh)
+	}
+	if !replaced {
+		nshs = append(nshs, sh)
+	}
+	return nshs
+}
+
+// without returns new hooks without the one with the passed name.
+func (shs shutdownHooks) without(name string) shutdownHooks {
+	nshs := make(shutdownHooks, 0, len(shs))
+	for _, csh := range shs {
+		if csh.name != name {
+			nshs = append(nshs, csh)
+		}
+	}
+	return nshs
+}
+
+// NewOSFatalExiter returns a fatal exiter exiting the application
+// with os.Exit and the passed exit code.
+func NewOSFatalExiter(code int) FatalExiterFunc {
+	return func() {
+		os.Exit(code)
+	}
+}
+
+// AddShutdownHook registers a named hook called in case of a fatal
+// entry. Hooks are called in the order of their registration, a hook
+// with an already registered name replaces the former one.
+func (l *Logger) AddShutdownHook(name string, hook ShutdownHookFunc) {
+	l.backend.mu.Lock()
+	defer l.backend.mu.Unlock()
+	l.backend.shutdownHooks = l.backend.shutdownHooks.with(shutdownHook{name, hook})
+}
+
+// RemoveShutdownHook removes the hook with the passed name.
+func (l *Logger) RemoveShutdownHook(name string) {
+	l.backend.mu.Lock()
+	defer l.backend.mu.Unlock()
+	l.backend.shutdownHooks = l.backend.shutdownHooks.without(name)
+}
+
+// SetShutdownTimeout sets the time all shutdown hooks together have
+// before the writers are closed and returns the current one.
+func (l *Logger) SetShutdownTimeout(timeout time.Duration) time.Duration {
+	l.backend.mu.Lock()
+	defer l.backend.mu.Unlock()
+	current := l.backend.shutdownTimeout
+	if timeout > 0 {
+		l.backend.shutdownTimeout = timeout
+	}
+	return current
+}
+
+// AddShutdownHook registers a named hook of the default logger.
+func AddShutdownHook(name string, hook ShutdownHookFunc) {
+	std.AddShutdownHook(name, hook)
+}
+
+// RemoveShutdownHook removes the named hook of the default logger.
+func RemoveShutdownHook(name string) {
+	std.RemoveShutdownHook(name)
+}
+
+// SetShutdownTimeout sets the shutdown timeout of the default logger.
+func SetShutdownTimeout(timeout time.Duration) time.Duration {
+	return std.SetShutdownTimeout(timeout)
+}
+
+//--------------------
+// SHUTDOWN
+//--------------------
+
+// fatal performs the shutdown in case of a fatal entry. It calls the
+// hooks, flushes and closes the writer, and finally calls the fatal
+// exiter. Flushing and closing reach the writers wrapped by the package
+// writers too, their errors are passed to the error handler. The lock is
+// not held meanwhile, so hooks can log too. A closed writer is replaced
+// by a standard writer to stderr, so entries logged after a fatal exiter
+// returning, e.g. in tests, don't get lost. This is noted there.
+func (lb *loggerBackend) fatal() {
+	lb.mu.RLock()
+	lbOut := lb.out
+	lbHooks := lb.shutdownHooks
+	lbTimeout := lb.shutdownTimeout
+	lbFatalExiter := lb.fatalExiter
+	lbErrorHandler := lb.errorHandler
+	lb.mu.RUnlock()
+	lb.runShutdownHooks(lbHooks, lbTimeout)
+	ferr := failure.Annotate(flushWriter(lbOut), "cannot flush writer at fatal shutdown")
+	closed, cerr := closeWriter(lbOut)
+	cerr = failure.Annotate(cerr, "cannot close writer at fatal shutdown")
+	if closed {
+		stderr := AdaptWriter(NewStandardWriter(os.Stderr))
+		lb.mu.Lock()
+		lb.out = stderr
+		lb.mu.Unlock()
+		_ = stderr.WriteEntry(Entry{
+			Time:    time.Now(),
+			Level:   LevelWarning,
+			Message: "writer closed at fatal shutdown, continuing on stderr",
+		})
+	}
+	if lbErrorHandler != nil {
+		for _, err := range []error{ferr, cerr} {
+			if err != nil {
+				lbErrorHandler(err)
+			}
+		}
+	}
+	lbFatalExiter()
+}
+
+// runShutdownHooks calls the hooks one after another until all are done
+// or the deadline is reached. Failures are logged.
+func (lb *loggerBackend) runShutdownHooks(hooks shutdownHooks, timeout time.Duration) {
+	if len(hooks) == 0 {
+		return
+	}
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for i, sh := range hooks {
+		errc := make(chan error, 1)
+		go func(hook ShutdownHookFunc) {
+			errc <- hook(ctx)
+		}(sh.hook)
+		select {
+		case err := <-errc:
+			if err != nil {
+				lb.outputShutdownError("shutdown hook failed", sh.name, err)
+			}
+		case <-ctx.Done():
+			lb.outputShutdownError("shutdown deadline exceeded", sh.name, ctx.Err())
+			for _, ssh := range hooks[i+1:] {
+				lb.outputShutdownError("shutdown hook skipped", ssh.name, ctx.Err())
+			}
+			return
+		}
+	}
+}
+
+// outputShutdownError writes an entry about a failed shutdown hook.
+func (lb *loggerBackend) outputShutdownError(msg, name string, err error) {
+	lb.output(Entry{
+		Time:    time.Now(),
+		Level:   LevelError,
+		Message: msg,
+		Fields:  Fields{F("hook", name), F("error", err)},
+	})
+}
+
+// EOF
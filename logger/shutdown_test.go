@@ -0,0 +1,212 @@
+// Tideland Go Trace - Logger - Unit Tests
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/trace/logger"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestShutdownHooks tests the ordered calling of the shutdown hooks.
+func TestShutdownHooks(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	cw := &closingWriter{CaptureWriter: logger.NewCaptureWriter()}
+	l := logger.New(cw)
+	var calls []string
+
+	l.AddShutdownHook("db", func(ctx context.Context) error {
+		calls = append(calls, "db")
+		// Logging inside of hooks must not deadlock.
+		l.Info("closing database")
+		return nil
+	})
+	l.AddShutdownHook("cache", func(ctx context.Context) error {
+		calls = append(calls, "cache")
+		return errors.New("cache is gone")
+	})
+	l.AddShutdownHook("queue", func(ctx context.Context) error {
+		calls = append(calls, "queue")
+		return nil
+	})
+	l.AddShutdownHook("db", func(ctx context.Context) error {
+		calls = append(calls, "db-new")
+		return nil
+	})
+	l.RemoveShutdownHook("queue")
+	l.SetFatalExiter(func() {
+		calls = append(calls, "exit")
+		// The lock is not held while exiting.
+		l.Info("exiting")
+	})
+
+	l.Fatal("fatal")
+	assert.Equal(calls, []string{"db-new", "cache", "exit"})
+	assert.True(cw.closed)
+	assert.True(cw.AssertLogged(t, logger.LevelError, `shutdown hook failed hook=cache error="cache is gone"`))
+	// Entry logged by the exiter went to the replacing writer.
+	assert.Equal(cw.Len(), 2)
+}
+
+// TestLoggingAfterFatal tests logging after a fatal exiter returned.
+func TestLoggingAfterFatal(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	filename := filepath.Join(t.TempDir(), "app.log")
+	fw, err := logger.NewFileWriter(logger.FileWriterConfig{
+		Filename: filename,
+	})
+	assert.Nil(err)
+	l := logger.New(fw)
+	l.SetFatalExiter(func() {})
+	var errs []error
+	l.SetErrorHandler(func(err error) {
+		errs = append(errs, err)
+	})
+
+	l.Fatal("fatal")
+	l.Info("after fatal")
+	assert.Length(errs, 0)
+	assert.True(l.SetWriter(nil) != logger.Writer(fw))
+
+	lines := readLines(assert, filename)
+	assert.Length(lines, 1)
+	assert.Contains("fatal", lines[0])
+}
+
+// TestFatalWrappedWriter tests flushing and closing an asynchronous
+// writer wrapped by a sampling writer at a fatal entry.
+func TestFatalWrappedWriter(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	cw := &closingWriter{
+		CaptureWriter: logger.NewCaptureWriter(),
+		delay:         5 * time.Millisecond,
+		err:           errors.New("disk is gone"),
+	}
+	aw := logger.NewAsyncWriter(cw, logger.AsyncConfig{})
+	sw := logger.NewSamplingWriter(aw, logger.SamplingConfig{
+		First:    100,
+		Interval: time.Hour,
+	})
+	l := logger.New(sw)
+	l.SetFatalExiter(func() {})
+	var errs []error
+	l.SetErrorHandler(func(err error) {
+		errs = append(errs, err)
+	})
+
+	for i := 0; i < 10; i++ {
+		l.Infof("entry %d", i)
+	}
+	l.Fatal("fatal")
+	assert.Equal(cw.Len(), 11)
+	assert.True(cw.closed)
+	assert.Equal(aw.Stats().Queued, 0)
+	assert.Length(errs, 1)
+	assert.ErrorContains(errs[0], "disk is gone")
+
+	// Redacting writer passing it to the asynchronous one.
+	cw = &closingWriter{
+		CaptureWriter: logger.NewCaptureWriter(),
+		delay:         5 * time.Millisecond,
+	}
+	l = logger.New(logger.NewRedactingWriter(logger.NewAsyncWriter(cw, logger.AsyncConfig{}), logger.DefaultRedactionConfig()))
+	l.SetFatalExiter(func() {})
+
+	for i := 0; i < 10; i++ {
+		l.Infof("entry %d", i)
+	}
+	l.Fatal("fatal")
+	assert.Equal(cw.Len(), 11)
+	assert.True(cw.closed)
+}
+
+// TestShutdownTimeout tests skipping the hooks after the deadline.
+func TestShutdownTimeout(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	cw := logger.NewCaptureWriter()
+	l := logger.New(cw)
+	exited := false
+
+	assert.Equal(l.SetShutdownTimeout(50*time.Millisecond), 5*time.Second)
+	l.AddShutdownHook("slow", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	l.AddShutdownHook("never", func(ctx context.Context) error {
+		return nil
+	})
+	l.SetFatalExiter(func() {
+		exited = true
+	})
+
+	start := time.Now()
+	l.Fatalf("fatal")
+	assert.True(time.Since(start) < time.Second)
+	assert.True(exited)
+	assert.True(cw.AssertLogged(t, logger.LevelError, "shutdown deadline exceeded hook=slow"))
+	assert.True(cw.AssertLogged(t, logger.LevelError, "shutdown hook skipped hook=never"))
+}
+
+// TestOSFatalExiterCode tests exiting with a configured exit code.
+func TestOSFatalExiterCode(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	if os.Getenv("TEST_FATAL_EXIT") == "1" {
+		l := logger.New(logger.NewTestWriter())
+		l.SetFatalExiter(logger.NewOSFatalExiter(3))
+		l.Fatal("fatal")
+		return
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestOSFatalExiterCode$")
+	cmd.Env = append(os.Environ(), "TEST_FATAL_EXIT=1")
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	assert.True(errors.As(err, &exitErr))
+	assert.Equal(exitErr.ExitCode(), 3)
+}
+
+//--------------------
+// HELPERS
+//--------------------
+
+// closingWriter is a capture writer noticing being closed. Optionally
+// it writes with a delay and fails when closing.
+type closingWriter struct {
+	logger.CaptureWriter
+	delay  time.Duration
+	err    error
+	closed bool
+}
+
+// WriteEntry implements logger.EntryWriter.
+func (cw *closingWriter) WriteEntry(entry logger.Entry) error {
+	time.Sleep(cw.delay)
+	return cw.CaptureWriter.WriteEntry(entry)
+}
+
+// Close implements io.Closer.
+func (cw *closingWriter) Close() error {
+	cw.closed = true
+	return cw.err
+}
+
+// EOF
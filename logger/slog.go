@@ -0,0 +1,232 @@
+// Tideland Go Trace - Logger - Bridge to log/slog
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger // import "tideland.dev/go/trace/logger"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"tideland.dev/go/trace/location"
+)
+
+//--------------------
+// SLOG LEVELS
+//--------------------
+
+// Additional slog levels for the critical and fatal log levels.
+const (
+	SlogLevelCritical slog.Level = slog.LevelError + 4
+	SlogLevelFatal    slog.Level = slog.LevelError + 8
+)
+
+// ToSlogLevel maps a log level onto the according slog level.
+func ToSlogLevel(level LogLevel) slog.Level {
+	switch {
+	case level <= LevelDebug:
+		return slog.LevelDebug
+	case level == LevelInfo:
+		return slog.LevelInfo
+	case level == LevelWarning:
+		return slog.LevelWarn
+	case level == LevelError:
+		return slog.LevelError
+	case level == LevelCritical:
+		return SlogLevelCritical
+	default:
+		return SlogLevelFatal
+	}
+}
+
+// FromSlogLevel maps a slog level onto the according log level. Levels
+// between the defined ones are mapped onto the lower one.
+func FromSlogLevel(level slog.Level) LogLevel {
+	switch {
+	case level < slog.LevelInfo:
+		return LevelDebug
+	case level < slog.LevelWarn:
+		return LevelInfo
+	case level < slog.LevelError:
+		return LevelWarning
+	case level < SlogLevelCritical:
+		return LevelError
+	case level < SlogLevelFatal:
+		return LevelCritical
+	default:
+		return LevelFatal
+	}
+}
+
+// ReplaceSlogLevel can be used as ReplaceAttr function of the slog
+// handler options. It renders the critical and fatal slog levels with
+// their names instead of "ERROR+4" and "ERROR+8".
+func ReplaceSlogLevel(groups []string, a slog.Attr) slog.Attr {
+	if a.Key != slog.LevelKey || len(groups) > 0 {
+		return a
+	}
+	level, ok := a.Value.Any().(slog.Level)
+	if !ok {
+		return a
+	}
+	switch {
+	case level >= SlogLevelFatal:
+		a.Value = slog.StringValue(levelToText(LevelFatal))
+	case level >= SlogLevelCritical:
+		a.Value = slog.StringValue(levelToText(LevelCritical))
+	}
+	return a
+}
+
+//--------------------
+// SLOG HANDLER
+//--------------------
+
+// slogHandler implements slog.Handler writing into a logger writer.
+type slogHandler struct {
+	out    EntryWriter
+	level  LogLevel
+	fields Fields
+	prefix string
+}
+
+// NewSlogHandler creates a slog.Handler writing the records with the
+// passed minimum level to the writer. Attributes are passed as fields,
+// groups are flattened into the keys separated by dots. The metadata
+// of the context like request and trace IDs is added.
+func NewSlogHandler(out Writer, level LogLevel) slog.Handler {
+	return &slogHandler{
+		out:   AdaptWriter(out),
+		level: level,
+	}
+}
+
+// Enabled implements slog.Handler.
+func (h *slogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return FromSlogLevel(level) >= h.level
+}
+
+// Handle implements slog.Handler.
+func (h *slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	cfs := contextFields(ctx)
+	fields := make(Fields, 0, len(cfs)+len(h.fields)+r.NumAttrs())
+	fields = append(fields, cfs...)
+	fields = append(fields, h.fields...)
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendSlogAttr(fields, h.prefix, a)
+		return true
+	})
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return h.out.WriteEntry(Entry{
+		Time:     t,
+		Level:    FromSlogLevel(r.Level),
+		Location: location.ForPC(r.PC),
+		Message:  r.Message,
+		Fields:   fields,
+		pc:       r.PC,
+	})
+}
+
+// WithAttrs implements slog.Handler.
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make(Fields, len(h.fields), len(h.fields)+len(attrs))
+	copy(fields, h.fields)
+	for _, a := range attrs {
+		fields = appendSlogAttr(fields, h.prefix, a)
+	}
+	return &slogHandler{
+		out:    h.out,
+		level:  h.level,
+		fields: fields,
+		prefix: h.prefix,
+	}
+}
+
+// WithGroup implements slog.Handler.
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &slogHandler{
+		out:    h.out,
+		level:  h.level,
+		fields: h.fields,
+		prefix: h.prefix + name + ".",
+	}
+}
+
+// appendSlogAttr appends the resolved attribute to the fields. Groups
+// are flattened, empty attributes are ignored.
+func appendSlogAttr(fields Fields, prefix string, a slog.Attr) Fields {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			fields = appendSlogAttr(fields, prefix, ga)
+		}
+		return fields
+	}
+	return append(fields, F(prefix+a.Key, a.Value.Any()))
+}
+
+//--------------------
+// SLOG WRITER
+//--------------------
+
+// slogWriter implements Writer forwarding to a slog.Handler.
+type slogWriter struct {
+	handler slog.Handler
+}
+
+// NewSlogWriter creates a writer forwarding the entries as records to
+// the passed slog.Handler. The location, if set, is passed as attribute
+// "location", the fields as attributes.
+func NewSlogWriter(handler slog.Handler) Writer {
+	return &slogWriter{
+		handler: handler,
+	}
+}
+
+// Write implements Writer.
+func (w *slogWriter) Write(level LogLevel, msg string) error {
+	return w.WriteEntry(Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: msg,
+	})
+}
+
+// WriteEntry implements EntryWriter.
+func (w *slogWriter) WriteEntry(entry Entry) error {
+	ctx := context.Background()
+	level := ToSlogLevel(entry.Level)
+	if !w.handler.Enabled(ctx, level) {
+		return nil
+	}
+	r := slog.NewRecord(entry.Time, level, entry.Message, 0)
+	if entry.Location.ID != "" {
+		r.AddAttrs(slog.String("location", entry.Location.ID))
+	}
+	for _, f := range entry.Fields {
+		r.AddAttrs(slog.Any(f.Key, f.Value))
+	}
+	return w.handler.Handle(ctx, r)
+}
+
+// EOF
@@ -0,0 +1,90 @@
+// Tideland Go Trace - Logger - Unit Tests
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/trace/logger"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestSlogLevels tests the mapping of levels between both packages.
+func TestSlogLevels(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+
+	for _, level := range []logger.LogLevel{
+		logger.LevelDebug,
+		logger.LevelInfo,
+		logger.LevelWarning,
+		logger.LevelError,
+		logger.LevelCritical,
+		logger.LevelFatal,
+	} {
+		assert.Equal(logger.FromSlogLevel(logger.ToSlogLevel(level)), level)
+	}
+	assert.Equal(logger.ToSlogLevel(logger.LevelWarning), slog.LevelWarn)
+	assert.Equal(logger.ToSlogLevel(logger.LevelCritical), logger.SlogLevelCritical)
+	assert.Equal(logger.FromSlogLevel(slog.LevelInfo+2), logger.LevelInfo)
+	assert.Equal(logger.FromSlogLevel(slog.LevelDebug-4), logger.LevelDebug)
+	assert.Equal(logger.FromSlogLevel(logger.SlogLevelFatal+100), logger.LevelFatal)
+}
+
+// TestSlogHandler tests logging with slog into a logger writer.
+func TestSlogHandler(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	tw := logger.NewTestWriter()
+	sl := slog.New(logger.NewSlogHandler(tw, logger.LevelInfo))
+
+	sl.Debug("dropped")
+	sl.Info("hello", "user", "foo")
+	sl.With("tenant", "acme").WithGroup("req").Warn("slow", "ms", 1200, slog.Group("db", "table", "users"))
+	sl.Log(context.Background(), logger.SlogLevelCritical, "critical")
+
+	es := tw.Entries()
+	assert.Length(es, 3)
+	assert.Contains("[INFO] (tideland.dev/go/trace/logger_test:slog_test.go:TestSlogHandler:", es[0])
+	assert.Contains("hello user=foo", es[0])
+	assert.Contains("[WARNING]", es[1])
+	assert.Contains("slow tenant=acme req.ms=1200 req.db.table=users", es[1])
+	assert.Contains("[CRITICAL]", es[2])
+}
+
+// TestSlogWriter tests logging into a slog handler.
+func TestSlogWriter(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	buf := &bytes.Buffer{}
+	h := slog.NewTextHandler(buf, &slog.HandlerOptions{
+		Level:       slog.LevelDebug,
+		ReplaceAttr: logger.ReplaceSlogLevel,
+	})
+	l := logger.New(logger.NewSlogWriter(h))
+	l.SetLevel(logger.LevelDebug)
+
+	l.Info("hello", "user", "foo")
+	l.Critical("broken", "n", 1)
+
+	out := buf.String()
+	assert.Contains(`level=INFO msg=hello user=foo`, out)
+	assert.Contains(`level=CRITICAL msg=broken location=`, out)
+	assert.Contains(`TestSlogWriter`, out)
+	assert.Contains(`n=1`, out)
+}
+
+// EOF
@@ -0,0 +1,367 @@
+// Tideland Go Trace - Logger - Syslog Writer
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger // import "tideland.dev/go/trace/logger"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"tideland.dev/go/trace/failure"
+)
+
+//--------------------
+// SYSLOG SETTINGS
+//--------------------
+
+// SyslogFacility describes the facility of syslog messages.
+type SyslogFacility int
+
+// Syslog facilities as defined in RFC 5424. The kernel facility
+// cannot be used by processes, so the zero value means FacilityUser.
+const (
+	FacilityUser SyslogFacility = iota + 1
+	FacilityMail
+	FacilityDaemon
+	FacilityAuth
+	FacilitySyslog
+	FacilityLPR
+	FacilityNews
+	FacilityUUCP
+	FacilityCron
+	FacilityAuthPriv
+	FacilityFTP
+	FacilityNTP
+	FacilityAudit
+	FacilityAlert
+	FacilityClock
+	FacilityLocal0
+	FacilityLocal1
+	FacilityLocal2
+	FacilityLocal3
+	FacilityLocal4
+	FacilityLocal5
+	FacilityLocal6
+	FacilityLocal7
+)
+
+// SyslogFormat describes the format of syslog messages.
+type SyslogFormat int
+
+// Supported syslog formats.
+const (
+	SyslogRFC5424 SyslogFormat = iota
+	SyslogRFC3164
+)
+
+// Networks for the syslog transport beside those of the net package.
+const (
+	NetworkLocal = ""
+	NetworkTLS   = "tls"
+)
+
+// defaultStructuredDataID is used for the fields of RFC 5424 messages.
+const defaultStructuredDataID = "fields@32473"
+
+// localSyslogPaths contains the paths of local syslog daemons.
+var localSyslogPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// SyslogConfig contains the configuration of a syslog writer.
+type SyslogConfig struct {
+	// Network is "udp", "tcp", "tls", "unix", "unixgram", or empty
+	// for the local syslog daemon.
+	Network string
+
+	// Address of the collector, e.g. "logs.example.com:514".
+	Address string
+
+	// TLSConfig is used for the network "tls".
+	TLSConfig *tls.Config
+
+	// Facility of the messages, by default FacilityUser.
+	Facility SyslogFacility
+
+	// AppName of the messages, by default the program name.
+	AppName string
+
+	// Hostname of the messages, by default the one of the system.
+	Hostname string
+
+	// Format of the messages, by default RFC 5424.
+	Format SyslogFormat
+
+	// StructuredDataID is the SD-ID of the element containing the fields
+	// in RFC 5424 messages. By default it is "fields@32473".
+	StructuredDataID string
+
+	// Timeout for connecting and writing, by default 5 seconds.
+	Timeout time.Duration
+}
+
+// severity maps the log levels to syslog severities.
+func severity(level LogLevel) int {
+	switch level {
+	case LevelDebug:
+		return 7
+	case LevelInfo:
+		return 6
+	case LevelWarning:
+		return 4
+	case LevelError:
+		return 3
+	case LevelCritical:
+		return 2
+	case LevelFatal:
+		return 0
+	default:
+		return 4
+	}
+}
+
+//--------------------
+// SYSLOG WRITER
+//--------------------
+
+// SyslogWriter is a writer sending entries to a syslog daemon
+// or collector.
+type SyslogWriter interface {
+	EntryWriter
+
+	// Close closes the connection.
+	Close() error
+}
+
+// syslogWriter implements SyslogWriter.
+type syslogWriter struct {
+	mu   sync.Mutex
+	cfg  SyslogConfig
+	pid  string
+	conn net.Conn
+}
+
+// NewSyslogWriter creates a writer formatting entries according to
+// RFC 5424 or RFC 3164 and sending them via the configured transport.
+// Streams like TCP and TLS use octet counting as framing.
+func NewSyslogWriter(cfg SyslogConfig) (SyslogWriter, error) {
+	if cfg.Facility < 0 || cfg.Facility > FacilityLocal7 {
+		return nil, failure.New("invalid syslog facility %d", cfg.Facility)
+	}
+	if cfg.Facility == 0 {
+		cfg.Facility = FacilityUser
+	}
+	if cfg.Network != NetworkLocal && cfg.Address == "" {
+		return nil, failure.New("missing syslog address for network %q", cfg.Network)
+	}
+	if cfg.AppName == "" {
+		cfg.AppName = filepath.Base(os.Args[0])
+	}
+	if cfg.Hostname == "" {
+		cfg.Hostname, _ = os.Hostname()
+	}
+	if cfg.StructuredDataID == "" {
+		cfg.StructuredDataID = defaultStructuredDataID
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	w := &syslogWriter{
+		cfg: cfg,
+		pid: strconv.Itoa(os.Getpid()),
+	}
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write implements Writer.
+func (w *syslogWriter) Write(level LogLevel, msg string) error {
+	return w.WriteEntry(Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: msg,
+	})
+}
+
+// WriteEntry implements EntryWriter.
+func (w *syslogWriter) WriteEntry(entry Entry) error {
+	var msg string
+	switch w.cfg.Format {
+	case SyslogRFC3164:
+		msg = w.formatRFC3164(entry)
+	default:
+		msg = w.formatRFC5424(entry)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// Try to reconnect once in case of an error.
+	err := w.send(msg)
+	if err == nil {
+		return nil
+	}
+	if err = w.connect(); err != nil {
+		return err
+	}
+	return w.send(msg)
+}
+
+// Close implements SyslogWriter.
+func (w *syslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// connect establishes the connection to the syslog daemon or collector.
+func (w *syslogWriter) connect() error {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+	var conn net.Conn
+	var err error
+	switch w.cfg.Network {
+	case NetworkLocal:
+		for _, path := range localSyslogPaths {
+			for _, network := range []string{"unixgram", "unix"} {
+				conn, err = net.DialTimeout(network, path, w.cfg.Timeout)
+				if err == nil {
+					w.conn = conn
+					return nil
+				}
+			}
+		}
+		return failure.New("cannot connect local syslog daemon")
+	case NetworkTLS:
+		dialer := &net.Dialer{Timeout: w.cfg.Timeout}
+		conn, err = tls.DialWithDialer(dialer, "tcp", w.cfg.Address, w.cfg.TLSConfig)
+	default:
+		conn, err = net.DialTimeout(w.cfg.Network, w.cfg.Address, w.cfg.Timeout)
+	}
+	if err != nil {
+		return failure.Annotate(err, "cannot connect syslog at %s %q", w.cfg.Network, w.cfg.Address)
+	}
+	w.conn = conn
+	return nil
+}
+
+// send writes the message to the connection. Streams are framed
+// with the octet count of the message.
+func (w *syslogWriter) send(msg string) error {
+	if w.conn == nil {
+		return failure.New("syslog writer is not connected")
+	}
+	if err := w.conn.SetWriteDeadline(time.Now().Add(w.cfg.Timeout)); err != nil {
+		return failure.Annotate(err, "cannot set syslog write deadline")
+	}
+	switch w.cfg.Network {
+	case "tcp", "tcp4", "tcp6", NetworkTLS:
+		msg = strconv.Itoa(len(msg)) + " " + msg
+	}
+	_, err := w.conn.Write([]byte(msg))
+	return failure.Annotate(err, "cannot write to syslog")
+}
+
+// priority returns the priority of the entry.
+func (w *syslogWriter) priority(entry Entry) int {
+	return int(w.cfg.Facility)*8 + severity(entry.Level)
+}
+
+// formatRFC3164 formats the entry according to RFC 3164.
+func (w *syslogWriter) formatRFC3164(entry Entry) string {
+	return fmt.Sprintf("<%d>%s %s %s[%s]: %s",
+		w.priority(entry),
+		entry.Time.Format(time.Stamp),
+		orNil(w.cfg.Hostname),
+		w.cfg.AppName,
+		w.pid,
+		entry.Text(),
+	)
+}
+
+// formatRFC5424 formats the entry according to RFC 5424. The fields
+// are passed as structured data.
+func (w *syslogWriter) formatRFC5424(entry Entry) string {
+	msg := entry.Message
+	if entry.Location.ID != "" {
+		msg = entry.Location.ID + " " + msg
+	}
+	return fmt.Sprintf("<%d>1 %s %s %s %s - %s %s",
+		w.priority(entry),
+		entry.Time.Format("2006-01-02T15:04:05.000000Z07:00"),
+		orNil(sdName(w.cfg.Hostname, 255)),
+		orNil(sdName(w.cfg.AppName, 48)),
+		w.pid,
+		structuredData(w.cfg.StructuredDataID, entry.Fields),
+		msg,
+	)
+}
+
+// structuredData renders the fields as structured data element.
+func structuredData(id string, fields Fields) string {
+	if len(fields) == 0 {
+		return "-"
+	}
+	var sb strings.Builder
+	sb.WriteString("[")
+	sb.WriteString(id)
+	for _, f := range fields {
+		sb.WriteString(" ")
+		sb.WriteString(orNil(sdName(f.Key, 32)))
+		sb.WriteString(`="`)
+		sb.WriteString(sdValue(fmt.Sprint(f.Value)))
+		sb.WriteString(`"`)
+	}
+	sb.WriteString("]")
+	return sb.String()
+}
+
+// sdName removes the characters not allowed in names and limits
+// the length.
+func sdName(name string, max int) string {
+	name = strings.Map(func(r rune) rune {
+		if r <= 32 || r >= 127 || r == '=' || r == ']' || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	if len(name) > max {
+		name = name[:max]
+	}
+	return name
+}
+
+// sdValue escapes the characters '"', '\', and ']' of values.
+func sdValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
+
+// orNil returns the nil value "-" for empty strings.
+func orNil(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// EOF
@@ -0,0 +1,179 @@
+// Tideland Go Trace - Logger - Unit Tests
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/trace/logger"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestSyslogUDP tests sending RFC 5424 messages via UDP.
+func TestSyslogUDP(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(err)
+	defer pc.Close()
+
+	sw, err := logger.NewSyslogWriter(logger.SyslogConfig{
+		Network:  "udp",
+		Address:  pc.LocalAddr().String(),
+		Facility: logger.FacilityLocal3,
+		AppName:  "myapp",
+		Hostname: "myhost",
+	})
+	assert.Nil(err)
+	defer sw.Close()
+	l := logger.New(sw)
+
+	l.Warning("disk full", "path", "/var", "quote", `a "b" ]`)
+	msg := readPacket(assert, pc)
+	// Priority is local3 (19) * 8 + warning (4).
+	assert.True(strings.HasPrefix(msg, "<156>1 "))
+	assert.Contains(" myhost myapp ", msg)
+	assert.Contains(` - [fields@32473 path="/var" quote="a \"b\" \]"] disk full`, msg)
+
+	l.Criticalf("broken")
+	msg = readPacket(assert, pc)
+	assert.True(strings.HasPrefix(msg, "<154>1 "))
+	assert.Contains(" - - (tideland.dev/go/trace/logger_test:syslog_test.go:TestSyslogUDP:", msg)
+}
+
+// TestSyslogTCP tests sending RFC 3164 messages via TCP.
+func TestSyslogTCP(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(err)
+	defer ln.Close()
+	msgc := serveFramed(ln)
+
+	sw, err := logger.NewSyslogWriter(logger.SyslogConfig{
+		Network:  "tcp",
+		Address:  ln.Addr().String(),
+		AppName:  "myapp",
+		Hostname: "myhost",
+		Format:   logger.SyslogRFC3164,
+	})
+	assert.Nil(err)
+	defer sw.Close()
+	l := logger.New(sw)
+
+	l.Info("user created", "user", "foo")
+	l.Error("failed")
+	msg := <-msgc
+	// Priority is user (1) * 8 + info (6).
+	assert.True(strings.HasPrefix(msg, "<14>"))
+	assert.Contains(" myhost myapp[", msg)
+	assert.Contains("]: user created user=foo", msg)
+	msg = <-msgc
+	assert.True(strings.HasPrefix(msg, "<11>"))
+}
+
+// TestSyslogTLS tests sending messages via TLS.
+func TestSyslogTLS(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	// Borrow the test certificate of a TLS test server.
+	srv := httptest.NewTLSServer(nil)
+	cert := srv.TLS.Certificates[0]
+	srv.Close()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	assert.Nil(err)
+	defer ln.Close()
+	msgc := serveFramed(ln)
+
+	sw, err := logger.NewSyslogWriter(logger.SyslogConfig{
+		Network:   logger.NetworkTLS,
+		Address:   ln.Addr().String(),
+		TLSConfig: &tls.Config{InsecureSkipVerify: true},
+	})
+	assert.Nil(err)
+	defer sw.Close()
+
+	assert.Nil(sw.Write(logger.LevelError, "secure"))
+	msg := <-msgc
+	assert.True(strings.HasPrefix(msg, "<11>1 "))
+	assert.True(strings.HasSuffix(msg, " - secure"))
+}
+
+// TestSyslogConfig tests the validation of the configuration.
+func TestSyslogConfig(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+
+	_, err := logger.NewSyslogWriter(logger.SyslogConfig{
+		Network:  "udp",
+		Address:  "127.0.0.1:514",
+		Facility: 42,
+	})
+	assert.ErrorContains(err, "invalid syslog facility 42")
+	_, err = logger.NewSyslogWriter(logger.SyslogConfig{
+		Network: "tcp",
+	})
+	assert.ErrorContains(err, `missing syslog address for network "tcp"`)
+}
+
+//--------------------
+// HELPERS
+//--------------------
+
+// readPacket reads one packet with a timeout.
+func readPacket(assert *asserts.Asserts, pc net.PacketConn) string {
+	buf := make([]byte, 4096)
+	pc.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	assert.Nil(err)
+	return string(buf[:n])
+}
+
+// serveFramed accepts one connection and emits the received
+// messages framed by octet counting.
+func serveFramed(ln net.Listener) chan string {
+	msgc := make(chan string, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			lenText, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(lenText))
+			if err != nil {
+				return
+			}
+			buf := make([]byte, n)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return
+			}
+			msgc <- string(buf)
+		}
+	}()
+	return msgc
+}
+
+// EOF
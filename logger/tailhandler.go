@@ -0,0 +1,180 @@
+// Tideland Go Trace - Logger - Tail Handler
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger // import "tideland.dev/go/trace/logger"
+
+//--------------------
+// IMPORT
+//--------------------
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"tideland.dev/go/trace/failure"
+)
+
+//--------------------
+// TAIL FILTER
+//--------------------
+
+// contentTypeEventStream is used for streaming with Server-Sent Events.
+const contentTypeEventStream = "text/event-stream"
+
+// followBuffer is the channel buffer of streaming requests.
+const followBuffer = 256
+
+// tailFilter selects entries by the query of a request.
+type tailFilter struct {
+	level    LogLevel
+	contains string
+	limit    int
+}
+
+// newTailFilter reads the filter from the query parameters "level",
+// "contains", and "limit".
+func newTailFilter(r *http.Request) (*tailFilter, error) {
+	query := r.URL.Query()
+	tf := &tailFilter{
+		level:    LevelDebug,
+		contains: query.Get("contains"),
+	}
+	if text := query.Get("level"); text != "" {
+		level, err := ParseLevel(text)
+		if err != nil {
+			return nil, err
+		}
+		tf.level = level
+	}
+	if text := query.Get("limit"); text != "" {
+		limit, err := strconv.Atoi(text)
+		if err != nil || limit < 0 {
+			return nil, failure.New("invalid limit %q", text)
+		}
+		tf.limit = limit
+	}
+	return tf, nil
+}
+
+// match checks if the entry matches the filter.
+func (tf *tailFilter) match(entry Entry) bool {
+	if entry.Level < tf.level {
+		return false
+	}
+	return tf.contains == "" || strings.Contains(entry.Text(), tf.contains)
+}
+
+// filter returns the matching entries, limited to the last ones.
+func (tf *tailFilter) filter(entries []Entry) []Entry {
+	var matching []Entry
+	for _, entry := range entries {
+		if tf.match(entry) {
+			matching = append(matching, entry)
+		}
+	}
+	if tf.limit > 0 && len(matching) > tf.limit {
+		matching = matching[len(matching)-tf.limit:]
+	}
+	return matching
+}
+
+//--------------------
+// TAIL HANDLER
+//--------------------
+
+// TailHandler implements the http.Handler for reading the entries
+// kept by a ring writer. They are returned as JSON array. Requests
+// accepting "text/event-stream" additionally receive the following
+// entries as Server-Sent Events. The query parameters "level",
+// "contains", and "limit" filter the entries by minimum level, text,
+// and number.
+type TailHandler struct {
+	ring RingWriter
+}
+
+// NewTailHandler returns an instance of a web handler for the passed
+// ring writer.
+func NewTailHandler(rw RingWriter) *TailHandler {
+	return &TailHandler{
+		ring: rw,
+	}
+}
+
+// ServeHTTP implements the handling function.
+func (h *TailHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "only GET allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	tf, err := newTailFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if strings.Contains(r.Header.Get("Accept"), contentTypeEventStream) {
+		h.stream(w, r, tf)
+		return
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, entry := range tf.filter(h.ring.Entries()) {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(marshalEntry(entry))
+	}
+	buf.WriteString("]\n")
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(buf.Bytes())
+}
+
+// stream sends the matching kept entries and all following ones as
+// events until the request is done.
+func (h *TailHandler) stream(w http.ResponseWriter, r *http.Request, tf *tailFilter) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	entries, entryc, stop := h.ring.Follow(followBuffer)
+	defer stop()
+	w.Header().Set("Content-Type", contentTypeEventStream)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	for _, entry := range tf.filter(entries) {
+		writeEvent(w, entry)
+	}
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case entry := <-entryc:
+			if !tf.match(entry) {
+				continue
+			}
+			if err := writeEvent(w, entry); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent writes the entry as Server-Sent Event.
+func writeEvent(w http.ResponseWriter, entry Entry) error {
+	var buf bytes.Buffer
+	buf.WriteString("data: ")
+	buf.Write(marshalEntry(entry))
+	buf.WriteString("\n\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// EOF
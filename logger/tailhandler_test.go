@@ -0,0 +1,133 @@
+// Tideland Go Trace - Logger - Unit Tests
+//
+// Copyright (C) 2012-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"bufio"
+	"net/http"
+	"strings"
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/audit/environments"
+	"tideland.dev/go/trace/logger"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestTailHandler tests retrieving the kept entries.
+func TestTailHandler(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	wa := environments.NewWebAsserter(assert)
+	defer wa.Close()
+
+	rw := logger.NewRingWriter(logger.RingConfig{MaxEntries: 10})
+	l := logger.New(rw)
+	l.Info("user created", "user", "foo")
+	l.Warning("disk almost full")
+	l.Error("disk full")
+	l.Error("user deleted", "user", "foo")
+
+	wa.Handle("/tail/", logger.NewTailHandler(rw))
+
+	wreq := wa.CreateRequest(http.MethodGet, "/tail/")
+	wresp := wreq.Do()
+	wresp.AssertStatusCodeEquals(http.StatusOK)
+	wresp.Header().AssertKeyContainsValue("Content-Type", environments.ContentTypeJSON)
+	var entries []map[string]interface{}
+	wresp.AssertUnmarshalledBody(&entries)
+	assert.Length(entries, 4)
+	assert.Equal(entries[0]["message"], "user created")
+	assert.Equal(entries[0]["fields"], map[string]interface{}{"user": "foo"})
+
+	wreq = wa.CreateRequest(http.MethodGet, "/tail/?level=warning&contains=disk")
+	wresp = wreq.Do()
+	entries = nil
+	wresp.AssertUnmarshalledBody(&entries)
+	assert.Length(entries, 2)
+	assert.Equal(entries[0]["level"], "WARNING")
+
+	wreq = wa.CreateRequest(http.MethodGet, "/tail/?contains=user=foo&limit=1")
+	wresp = wreq.Do()
+	entries = nil
+	wresp.AssertUnmarshalledBody(&entries)
+	assert.Length(entries, 1)
+	assert.Equal(entries[0]["message"], "user deleted")
+
+	tests := []struct {
+		path string
+		msg  string
+	}{
+		{"/tail/?level=loud", `invalid log level "loud"`},
+		{"/tail/?limit=many", `invalid limit "many"`},
+	}
+	for _, test := range tests {
+		wreq = wa.CreateRequest(http.MethodGet, test.path)
+		wresp = wreq.Do()
+		wresp.AssertStatusCodeEquals(http.StatusBadRequest)
+		wresp.AssertBodyContains(test.msg)
+	}
+
+	wreq = wa.CreateRequest(http.MethodPost, "/tail/")
+	wresp = wreq.Do()
+	wresp.AssertStatusCodeEquals(http.StatusMethodNotAllowed)
+}
+
+// TestTailHandlerStream tests streaming entries as Server-Sent Events.
+func TestTailHandlerStream(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	wa := environments.NewWebAsserter(assert)
+	defer wa.Close()
+
+	rw := logger.NewRingWriter(logger.RingConfig{})
+	l := logger.New(rw)
+	l.Info("before")
+	l.Error("failed before")
+
+	wa.Handle("/tail/", logger.NewTailHandler(rw))
+
+	req, err := http.NewRequest(http.MethodGet, wa.URL()+"/tail/?level=error", nil)
+	assert.Nil(err)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(err)
+	defer resp.Body.Close()
+	assert.Equal(resp.Header.Get("Content-Type"), "text/event-stream")
+
+	events := bufio.NewReader(resp.Body)
+	event := readEvent(assert, events)
+	assert.Contains(`"message":"failed before"`, event)
+
+	l.Info("after")
+	l.Error("failed after", "code", 42)
+	event = readEvent(assert, events)
+	assert.Contains(`"message":"failed after","fields":{"code":42}`, event)
+}
+
+//--------------------
+// HELPERS
+//--------------------
+
+// readEvent reads the data of the next Server-Sent Event.
+func readEvent(assert *asserts.Asserts, r *bufio.Reader) string {
+	line, err := r.ReadString('\n')
+	assert.Nil(err)
+	assert.True(strings.HasPrefix(line, "data: "))
+	empty, err := r.ReadString('\n')
+	assert.Nil(err)
+	assert.Equal(empty, "\n")
+	return strings.TrimPrefix(strings.TrimSpace(line), "data: ")
+}
+
+// EOF
@@ -54,21 +54,69 @@ type Writer interface {
 	Write(level LogLevel, msg string) error
 }
 
-// standardWriter is a simple writer writing to the given I/O
-// writer. Beside the output it doesn't handle the levels differently.
-type standardWriter struct {
-	mu         sync.Mutex
-	out        io.Writer
-	timeFormat string
+// Flusher is implemented by writers buffering the entries.
+type Flusher interface {
+	// Flush writes all buffered entries.
+	Flush() error
+}
+
+// wrapper is implemented by writers wrapping another one like the
+// asynchronous, the redacting, or the sampling writer.
+type wrapper interface {
+	// unwrap returns the wrapped writer.
+	unwrap() Writer
+}
+
+// findWriter returns the first writer of the chain of wrapped writers
+// starting with the passed one for which the match function returns true.
+func findWriter(w Writer, match func(w Writer) bool) (Writer, bool) {
+	for w != nil {
+		if ew, ok := w.(EntryWriter); ok {
+			w = unadaptWriter(ew)
+		}
+		if match(w) {
+			return w, true
+		}
+		ww, ok := w.(wrapper)
+		if !ok {
+			break
+		}
+		w = ww.unwrap()
+	}
+	return nil, false
+}
+
+// flushWriter flushes the first writer of the chain of wrapped writers
+// implementing Flusher. Flushing wrappers pass it on to the wrapped ones.
+func flushWriter(w Writer) error {
+	fw, ok := findWriter(w, func(w Writer) bool {
+		_, ok := w.(Flusher)
+		return ok
+	})
+	if !ok {
+		return nil
+	}
+	return fw.(Flusher).Flush()
+}
+
+// closeWriter closes the first writer of the chain of wrapped writers
+// implementing io.Closer and returns if one has been found. Closing
+// wrappers pass it on to the wrapped ones.
+func closeWriter(w Writer) (bool, error) {
+	cw, ok := findWriter(w, func(w Writer) bool {
+		_, ok := w.(io.Closer)
+		return ok
+	})
+	if !ok {
+		return false, nil
+	}
+	return true, cw.(io.Closer).Close()
 }
 
 // NewTimeformatWriter creates a writer writing to the passed
 // output and with the specified time format.
 func NewTimeformatWriter(out io.Writer, timeFormat string) Writer {
-	return &standardWriter{
-		out:        out,
-		timeFormat: timeFormat,
-	}
+	return NewFormatWriter(out, NewTextFormatter(timeFormat))
 }
 
 // NewStandardWriter creates the standard writer writing
@@ -83,28 +131,43 @@ func NewStandardOutWriter() Writer {
 	return NewStandardWriter(os.Stdout)
 }
 
-// Write implements Writer.
-func (w *standardWriter) Write(level LogLevel, msg string) error {
-	w.mu.Lock()
-	defer w.mu.Unlock()
-	now := time.Now().Format(w.timeFormat)
-	text := levelToText(level)
-	_, err := fmt.Fprintf(w.out, "%s [%s] %s\n", now, text, msg)
-	return err
-}
-
 // goWriter just uses the standard go log package.
-type goWriter struct{}
+type goWriter struct {
+	formatter Formatter
+}
 
 // NewGoWriter creates a writer using the Go log package.
 func NewGoWriter() Writer {
-	return &goWriter{}
+	return NewGoFormatWriter(FormatterFunc(func(entry Entry) ([]byte, error) {
+		return []byte("[" + levelToText(entry.Level) + "] " + entry.Text()), nil
+	}))
+}
+
+// NewGoFormatWriter creates a writer using the Go log package with
+// the passed formatter. Prefix, flags, and output are still those
+// of the Go log package, so the formatter may omit the timestamp.
+func NewGoFormatWriter(formatter Formatter) Writer {
+	return &goWriter{
+		formatter: formatter,
+	}
 }
 
 // Write implements Writer.
 func (w *goWriter) Write(level LogLevel, msg string) error {
-	text := levelToText(level)
-	log.Println("["+text+"]", msg)
+	return w.WriteEntry(Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: msg,
+	})
+}
+
+// WriteEntry implements EntryWriter.
+func (w *goWriter) WriteEntry(entry Entry) error {
+	data, err := w.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	log.Print(string(data))
 	return nil
 }
 
@@ -140,11 +203,20 @@ func NewTestWriter() TestWriter {
 
 // Write implements Writer.
 func (w *testWriter) Write(level LogLevel, msg string) error {
+	return w.WriteEntry(Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: msg,
+	})
+}
+
+// WriteEntry implements EntryWriter.
+func (w *testWriter) WriteEntry(entry Entry) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
-	text := levelToText(level)
-	entry := fmt.Sprintf("%d [%s] %s", time.Now().UnixNano(), text, msg)
-	w.entries = append(w.entries, entry)
+	text := levelToText(entry.Level)
+	line := fmt.Sprintf("%d [%s] %s", entry.Time.UnixNano(), text, entry.Text())
+	w.entries = append(w.entries, line)
 	return nil
 }
 